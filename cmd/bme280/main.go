@@ -15,10 +15,12 @@ import (
 
 	"periph.io/x/periph/conn/i2c"
 	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/i2c/i2ctest"
 	"periph.io/x/periph/conn/pin"
 	"periph.io/x/periph/conn/pin/pinreg"
 	"periph.io/x/periph/conn/spi"
 	"periph.io/x/periph/conn/spi/spireg"
+	"periph.io/x/periph/conn/spi/spitest"
 	"periph.io/x/periph/devices"
 	"periph.io/x/periph/devices/bme280"
 	"periph.io/x/periph/host"
@@ -63,6 +65,7 @@ func mainImpl() error {
 	filter16x := flag.Bool("f16", false, "filter IIR at 16x")
 	loop := flag.Bool("l", false, "loop every 100ms")
 	verbose := flag.Bool("v", false, "verbose mode")
+	record := flag.String("record", "", "record the I/O in this golden file, to replay it in unit tests")
 	flag.Parse()
 	if !*verbose {
 		log.SetOutput(ioutil.Discard)
@@ -103,6 +106,8 @@ func mainImpl() error {
 	}
 
 	var dev *bme280.Dev
+	// save is set when recording.
+	var save func() error
 	if *spiID != "" {
 		bus, err := spireg.Open(*spiID)
 		if err != nil {
@@ -119,7 +124,13 @@ func mainImpl() error {
 		if err := bus.Speed(100000); err != nil {
 			return err
 		}
-		if dev, err = bme280.NewSPI(bus, &opts); err != nil {
+		var c spi.Conn = bus
+		if *record != "" {
+			r := &spitest.Record{Conn: bus}
+			c = r
+			save = func() error { return r.Save(*record) }
+		}
+		if dev, err = bme280.NewSPI(c, &opts); err != nil {
 			return err
 		}
 	} else {
@@ -132,7 +143,13 @@ func mainImpl() error {
 			printPin("SCL", p.SCL())
 			printPin("SDA", p.SDA())
 		}
-		if dev, err = bme280.NewI2C(bus, &opts); err != nil {
+		var b i2c.Bus = bus
+		if *record != "" {
+			r := &i2ctest.Record{Bus: bus}
+			b = r
+			save = func() error { return r.Save(*record) }
+		}
+		if dev, err = bme280.NewI2C(b, &opts); err != nil {
 			return err
 		}
 	}
@@ -142,7 +159,13 @@ func mainImpl() error {
 	if err != nil {
 		return err
 	}
-	return err2
+	if err2 != nil {
+		return err2
+	}
+	if save != nil {
+		return save()
+	}
+	return nil
 }
 
 func main() {
@@ -3,9 +3,14 @@
 // that can be found in the LICENSE file.
 
 // i2c-list lists all I²C buses.
+//
+// With -scan, it probes for the devices on each bus and prints an address grid
+// like i2cdetect.
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 
@@ -25,10 +30,76 @@ func printPin(fn string, p pin.Pin) {
 	}
 }
 
+// printGrid prints the devices found in the format used by i2cdetect.
+func printGrid(devs []i2c.Dev, first, last uint16) {
+	found := map[uint16]bool{}
+	for _, d := range devs {
+		found[d.Addr] = true
+	}
+	fmt.Print("     0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f\n")
+	for row := uint16(0); row < 0x80; row += 0x10 {
+		fmt.Printf("%02x:", row)
+		for addr := row; addr < row+0x10; addr++ {
+			switch {
+			case addr < first || addr > last:
+				fmt.Print("   ")
+			case found[addr]:
+				fmt.Printf(" %02x", addr)
+			default:
+				fmt.Print(" --")
+			}
+		}
+		fmt.Print("\n")
+	}
+}
+
+func scan(bus i2c.Bus, opts *i2c.ScanOpts) error {
+	devs, err := i2c.Scan(bus, opts)
+	if err != nil {
+		return err
+	}
+	printGrid(devs, opts.First, opts.Last)
+	return nil
+}
+
 func mainImpl() error {
+	doScan := flag.Bool("scan", false, "probe for devices on the buses")
+	busName := flag.String("b", "", "I²C bus to scan; defaults to all of them")
+	quick := flag.Bool("q", false, "probe only with quick writes; can corrupt some EEPROMs")
+	read := flag.Bool("r", false, "probe only by reading a byte; can lock up write-only devices")
+	all := flag.Bool("a", false, "probe the reserved addresses 0x00-0x07 and 0x78-0x7f too")
+	flag.Parse()
+	if flag.NArg() != 0 {
+		return errors.New("unexpected argument, try -help")
+	}
+	if *quick && *read {
+		return errors.New("use only one of -q or -r")
+	}
+	opts := i2c.ScanOpts{First: 0x08, Last: 0x77}
+	if *quick {
+		opts.Mode = i2c.ScanQuick
+	} else if *read {
+		opts.Mode = i2c.ScanRead
+	}
+	if *all {
+		opts.First = 0x00
+		opts.Last = 0x7F
+	}
 	if _, err := host.Init(); err != nil {
 		return err
 	}
+
+	if *busName != "" {
+		if !*doScan {
+			return errors.New("-b can only be used with -scan")
+		}
+		bus, err := i2creg.Open(*busName)
+		if err != nil {
+			return err
+		}
+		defer bus.Close()
+		return scan(bus, &opts)
+	}
 	for _, ref := range i2creg.All() {
 		fmt.Printf("%s:\n", ref.Name)
 		bus, err := ref.Open()
@@ -40,6 +111,12 @@ func mainImpl() error {
 			printPin("SCL", p.SCL())
 			printPin("SDA", p.SDA())
 		}
+		if *doScan {
+			if err := scan(bus, &opts); err != nil {
+				bus.Close()
+				return err
+			}
+		}
 		if err := bus.Close(); err != nil {
 			return err
 		}
@@ -6,13 +6,64 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"periph.io/x/periph"
 	"periph.io/x/periph/host"
 )
 
+// driver is the JSON representation of a driver.
+type driver struct {
+	Name          string
+	Stage         int
+	Prerequisites []string
+	Duration      time.Duration // in nanoseconds
+	Err           string        `json:",omitempty"`
+}
+
+// output is the JSON representation of periph.State.
+type output struct {
+	Loaded  []driver
+	Skipped []driver
+	Failed  []driver
+}
+
+func toDriver(info map[periph.Driver]periph.DriverInfo, d periph.Driver, err error) driver {
+	i := info[d]
+	out := driver{Name: d.String(), Stage: i.Stage, Prerequisites: []string{}, Duration: i.Duration}
+	for _, p := range i.Prerequisites {
+		out.Prerequisites = append(out.Prerequisites, p.String())
+	}
+	if err != nil {
+		out.Err = err.Error()
+	}
+	return out
+}
+
+func printJSON(state *periph.State, info map[periph.Driver]periph.DriverInfo) error {
+	out := output{Loaded: []driver{}, Skipped: []driver{}, Failed: []driver{}}
+	for _, d := range state.Loaded {
+		out.Loaded = append(out.Loaded, toDriver(info, d, nil))
+	}
+	for _, f := range state.Skipped {
+		out.Skipped = append(out.Skipped, toDriver(info, f.D, f.Err))
+	}
+	for _, f := range state.Failed {
+		out.Failed = append(out.Failed, toDriver(info, f.D, f.Err))
+	}
+	b, err := json.MarshalIndent(&out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Printf("%s\n", b)
+	return err
+}
+
 func printDrivers(drivers []periph.DriverFailure) {
 	if len(drivers) == 0 {
 		fmt.Print("  <none>\n")
@@ -29,11 +80,37 @@ func printDrivers(drivers []periph.DriverFailure) {
 	}
 }
 
+func printTimings(state *periph.State) {
+	max := 0
+	for _, i := range state.Info {
+		if m := len(i.D.String()); m > max {
+			max = m
+		}
+	}
+	for _, i := range state.Info {
+		fmt.Printf("- %-*s: stage %d, %s\n", max, i.D, i.Stage, i.Duration)
+	}
+}
+
 func mainImpl() error {
+	asJSON := flag.Bool("json", false, "print the drivers state as JSON")
+	timings := flag.Bool("t", false, "print the initialization stage and duration of each driver")
+	flag.Parse()
+	if flag.NArg() != 0 {
+		return errors.New("unexpected argument, try -help")
+	}
+
 	state, err := host.Init()
 	if err != nil {
 		return err
 	}
+	if *asJSON {
+		info := map[periph.Driver]periph.DriverInfo{}
+		for _, i := range state.Info {
+			info[i.D] = i
+		}
+		return printJSON(state, info)
+	}
 
 	fmt.Printf("Drivers loaded and their dependencies, if any:\n")
 	if len(state.Loaded) == 0 {
@@ -58,6 +135,10 @@ func mainImpl() error {
 	printDrivers(state.Skipped)
 	fmt.Printf("Drivers failed to load and the error:\n")
 	printDrivers(state.Failed)
+	if *timings {
+		fmt.Printf("Drivers initialization stage and duration:\n")
+		printTimings(state)
+	}
 	return err
 }
 
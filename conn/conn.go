@@ -4,7 +4,10 @@
 
 package conn
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 // Duplex declares whether communication can happen simultaneously both ways.
 //
@@ -71,3 +74,40 @@ type Conn interface {
 	// in an unknown state.
 	Duplex() Duplex
 }
+
+// ConnContext is optionally implemented by a Conn whose transactions can be
+// cancelled.
+type ConnContext interface {
+	// TxContext does a single transaction like Tx() but gives up when ctx is
+	// done, in which case ctx.Err() is returned.
+	//
+	// Whether a partial transaction was done on the wire when the transaction is
+	// cancelled is implementation specific.
+	TxContext(ctx context.Context, w, r []byte) error
+}
+
+// TxContext does a single transaction on c that can be cancelled via ctx.
+//
+// It calls c.TxContext() if c implements ConnContext. Otherwise it checks ctx
+// before calling c.Tx(), which cannot be interrupted.
+func TxContext(ctx context.Context, c Conn, w, r []byte) error {
+	if cc, ok := c.(ConnContext); ok {
+		return cc.TxContext(ctx, w, r)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Tx(w, r)
+}
+
+// Limits is optionally implemented by a Conn to report the limits of its
+// transactions.
+type Limits interface {
+	// MaxTxSize returns the maximum number of bytes that can be sent or
+	// received in a single transaction, or 0 if there is no limit.
+	//
+	// An implementation may transparently split larger transactions. In that
+	// case it returns the largest size that is guaranteed to be done as a
+	// single unit.
+	MaxTxSize() int
+}
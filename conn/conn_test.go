@@ -4,10 +4,55 @@
 
 package conn
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"testing"
+)
 
 func TestDuplex(t *testing.T) {
 	if Half.String() != "Half" || Duplex(10).String() != "Duplex(10)" {
 		t.Fatal()
 	}
 }
+
+func TestTxContext(t *testing.T) {
+	c := &fakeConn{}
+	if err := TxContext(context.Background(), c, []byte{1}, nil); err != nil || c.tx != 1 {
+		t.Fatal(err, c.tx)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := TxContext(ctx, c, []byte{1}, nil); err != context.Canceled || c.tx != 1 {
+		t.Fatal(err, c.tx)
+	}
+	cc := &fakeConnContext{}
+	if err := TxContext(ctx, cc, []byte{1}, nil); err != errCtx || cc.tx != 0 {
+		t.Fatal(err, cc.tx)
+	}
+}
+
+//
+
+var errCtx = errors.New("ctx")
+
+type fakeConn struct {
+	tx int
+}
+
+func (f *fakeConn) Tx(w, r []byte) error {
+	f.tx++
+	return nil
+}
+
+func (f *fakeConn) Duplex() Duplex {
+	return Half
+}
+
+type fakeConnContext struct {
+	fakeConn
+}
+
+func (f *fakeConnContext) TxContext(ctx context.Context, w, r []byte) error {
+	return errCtx
+}
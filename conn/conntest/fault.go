@@ -0,0 +1,142 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn"
+)
+
+// Faults describes the faults to inject in the transactions of a connection.
+//
+// It is used by Fault and by the equivalent wrappers in i2ctest, spitest and
+// onewiretest. The zero value injects no fault.
+type Faults struct {
+	sync.Mutex
+	// Every, when not 0, makes every Nth transaction fail with Err without
+	// reaching the wrapped connection.
+	Every int
+	// Err is the error returned by the failing transactions. It defaults to a
+	// generic error. Use BusError or ShortedBusError to simulate 1-wire errors.
+	Err error
+	// BitFlip is the probability, between 0 and 1, to flip a random bit in
+	// each byte read.
+	BitFlip float64
+	// Delay is the latency added to each transaction.
+	Delay time.Duration
+	// Rand is the source of randomness for BitFlip. It defaults to a source
+	// with a constant seed so tests are reproducible.
+	Rand *rand.Rand
+	// Count is the number of transactions done so far, including the failed
+	// ones.
+	Count int
+}
+
+// Inject is meant to be called before each transaction.
+//
+// It sleeps for Delay and returns Err if this transaction must fail.
+func (f *Faults) Inject() error {
+	f.Lock()
+	f.Count++
+	fail := f.Every != 0 && f.Count%f.Every == 0
+	err := f.Err
+	d := f.Delay
+	f.Unlock()
+	if d != 0 {
+		time.Sleep(d)
+	}
+	if !fail {
+		return nil
+	}
+	if err == nil {
+		err = errors.New("conntest: injected fault")
+	}
+	return err
+}
+
+// Corrupt is meant to be called after each successful transaction with the
+// bytes read.
+//
+// It flips random bits in r as specified by BitFlip.
+func (f *Faults) Corrupt(r []byte) {
+	f.Lock()
+	defer f.Unlock()
+	if f.BitFlip <= 0 {
+		return
+	}
+	if f.Rand == nil {
+		f.Rand = rand.New(rand.NewSource(1))
+	}
+	for i := range r {
+		if f.Rand.Float64() < f.BitFlip {
+			r[i] ^= 1 << uint(f.Rand.Intn(8))
+		}
+	}
+}
+
+// Fault implements conn.Conn and injects faults in the transactions done on
+// Conn.
+type Fault struct {
+	Faults
+	Conn conn.Conn
+}
+
+func (f *Fault) String() string {
+	return "fault"
+}
+
+// Tx implements conn.Conn.
+func (f *Fault) Tx(w, r []byte) error {
+	if err := f.Inject(); err != nil {
+		return err
+	}
+	if err := f.Conn.Tx(w, r); err != nil {
+		return err
+	}
+	f.Corrupt(r)
+	return nil
+}
+
+// Duplex implements conn.Conn.
+func (f *Fault) Duplex() conn.Duplex {
+	return f.Conn.Duplex()
+}
+
+// BusError is an error implementing onewire.BusError, e.g. to simulate a CRC
+// error or a non-responding device.
+type BusError string
+
+func (e BusError) Error() string {
+	return string(e)
+}
+
+// BusError implements onewire.BusError.
+func (e BusError) BusError() bool {
+	return true
+}
+
+// ShortedBusError is an error implementing onewire.ShortedBusError and
+// onewire.BusError.
+type ShortedBusError string
+
+func (e ShortedBusError) Error() string {
+	return string(e)
+}
+
+// IsShorted implements onewire.ShortedBusError.
+func (e ShortedBusError) IsShorted() bool {
+	return true
+}
+
+// BusError implements onewire.BusError.
+func (e ShortedBusError) BusError() bool {
+	return true
+}
+
+var _ conn.Conn = &Fault{}
@@ -0,0 +1,111 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn"
+)
+
+func TestFault_every(t *testing.T) {
+	p := Playback{
+		Ops: []IO{
+			{Write: []byte{1}, Read: []byte{2}},
+			{Write: []byte{1}, Read: []byte{2}},
+		},
+		D: conn.Full,
+	}
+	errFoo := errors.New("foo")
+	f := Fault{Faults: Faults{Every: 2, Err: errFoo}, Conn: &p}
+	if s := f.String(); s != "fault" {
+		t.Fatal(s)
+	}
+	if d := f.Duplex(); d != conn.Full {
+		t.Fatal(d)
+	}
+	r := make([]byte, 1)
+	for i, expected := range []error{nil, errFoo, nil} {
+		if err := f.Tx([]byte{1}, r); err != expected {
+			t.Fatalf("%d: %v", i, err)
+		}
+	}
+	if f.Count != 3 {
+		t.Fatal(f.Count)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFault_defaultErr(t *testing.T) {
+	f := Fault{Faults: Faults{Every: 1}, Conn: &Discard{}}
+	if err := f.Tx(nil, nil); err == nil || err.Error() != "conntest: injected fault" {
+		t.Fatal(err)
+	}
+}
+
+func TestFault_bitFlip(t *testing.T) {
+	p := Playback{Ops: []IO{{Read: make([]byte, 64)}}}
+	f := Fault{Faults: Faults{BitFlip: 1}, Conn: &p}
+	r := make([]byte, 64)
+	if err := f.Tx(nil, r); err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range r {
+		// Exactly one bit must be set.
+		if b == 0 || b&(b-1) != 0 {
+			t.Fatalf("%d: 0x%02X", i, b)
+		}
+	}
+	// The same seed gives the same result.
+	p = Playback{Ops: []IO{{Read: make([]byte, 64)}}}
+	f2 := Fault{Faults: Faults{BitFlip: 1}, Conn: &p}
+	r2 := make([]byte, 64)
+	if err := f2.Tx(nil, r2); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, r2) {
+		t.Fatal("expected reproducible bit flips")
+	}
+}
+
+func TestFault_delay(t *testing.T) {
+	f := Fault{Faults: Faults{Delay: time.Millisecond}, Conn: &Discard{}}
+	start := time.Now()
+	if err := f.Tx(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < time.Millisecond {
+		t.Fatal(d)
+	}
+}
+
+func TestBusError(t *testing.T) {
+	// Interfaces as defined in package onewire.
+	type busError interface {
+		BusError() bool
+	}
+	type shortedBusError interface {
+		IsShorted() bool
+	}
+	var err error = BusError("crc")
+	if b, ok := err.(busError); !ok || !b.BusError() || err.Error() != "crc" {
+		t.Fatal(err)
+	}
+	if _, ok := err.(shortedBusError); ok {
+		t.Fatal("BusError is not shorted")
+	}
+	err = ShortedBusError("shorted")
+	if b, ok := err.(busError); !ok || !b.BusError() || err.Error() != "shorted" {
+		t.Fatal(err)
+	}
+	if s, ok := err.(shortedBusError); !ok || !s.IsShorted() {
+		t.Fatal(err)
+	}
+}
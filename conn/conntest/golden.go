@@ -0,0 +1,165 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// The golden file format is a text format to persist recorded I/O, so it can
+// be committed alongside the unit tests and replayed with Playback.
+//
+// Each line is an operation. Empty lines and lines starting with '#' are
+// ignored. An operation is a list of space separated key=value fields, where
+// the bytes are hex encoded. For example, writing 0xD0 then reading 0x60 is:
+//
+//	w=d0 r=60
+//
+// The field w is always present, r is omitted when nothing was read.
+//
+// Packages like i2ctest and onewiretest extend the format with their own
+// fields via DecodeLines() and ParseLine().
+
+// MarshalText implements encoding.TextMarshaler using the golden file format.
+func (i IO) MarshalText() ([]byte, error) {
+	s := "w=" + hex.EncodeToString(i.Write)
+	if len(i.Read) != 0 {
+		s += " r=" + hex.EncodeToString(i.Read)
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using the golden file
+// format.
+func (i *IO) UnmarshalText(text []byte) error {
+	if err := ParseLine(string(text), i, nil); err != nil {
+		return errors.New("conntest: " + err.Error())
+	}
+	return nil
+}
+
+// Encode writes ops in the golden file format.
+func Encode(w io.Writer, ops []IO) error {
+	for _, op := range ops {
+		b, _ := op.MarshalText()
+		if _, err := fmt.Fprintf(w, "%s\n", b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode reads ops in the golden file format.
+func Decode(r io.Reader) ([]IO, error) {
+	var ops []IO
+	err := DecodeLines(r, func(line string) error {
+		var op IO
+		if err := ParseLine(line, &op, nil); err != nil {
+			return err
+		}
+		ops = append(ops, op)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("conntest: " + err.Error())
+	}
+	return ops, nil
+}
+
+// DecodeLines reads the golden file from r and calls parse for each
+// operation, skipping empty lines and comments.
+//
+// The error returned by parse is prefixed with the line number.
+func DecodeLines(r io.Reader, parse func(line string) error) error {
+	b := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := b.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if l := strings.TrimSpace(line); l != "" && l[0] != '#' {
+			if err2 := parse(l); err2 != nil {
+				return fmt.Errorf("line %d: %v", n, err2)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// ParseLine parses an operation in the golden file format into i.
+//
+// The fields other than w and r are passed to extra, which returns false if
+// the field is unknown. extra can be nil.
+func ParseLine(line string, i *IO, extra func(key, value string) (bool, error)) error {
+	*i = IO{}
+	found := false
+	for _, f := range strings.Fields(line) {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid field %q", f)
+		}
+		var err error
+		switch kv[0] {
+		case "w":
+			i.Write, err = hex.DecodeString(kv[1])
+			found = true
+		case "r":
+			i.Read, err = hex.DecodeString(kv[1])
+		default:
+			ok := false
+			if extra != nil {
+				ok, err = extra(kv[0], kv[1])
+			}
+			if !ok && err == nil {
+				return fmt.Errorf("unknown field %q", f)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid field %q: %v", f, err)
+		}
+	}
+	if !found {
+		return errors.New("missing field w")
+	}
+	return nil
+}
+
+// LoadPlayback returns a Playback with the operations read from the golden
+// file at path.
+func LoadPlayback(path string) (*Playback, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ops, err := Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return &Playback{Ops: ops}, nil
+}
+
+// Save writes the recorded operations to the golden file at path.
+func (r *Record) Save(path string) error {
+	r.Lock()
+	defer r.Unlock()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Encode(f, r.Ops); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,123 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	ops := []IO{
+		{Write: []byte{0xD0}, Read: []byte{0x60}},
+		{Write: []byte{}},
+		{Write: []byte{1, 2, 3}},
+	}
+	b := bytes.Buffer{}
+	if err := Encode(&b, ops); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); s != "w=d0 r=60\nw=\nw=010203\n" {
+		t.Fatalf("%q", s)
+	}
+	got, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, ops) {
+		t.Fatalf("%#v", got)
+	}
+}
+
+func TestDecode_comments(t *testing.T) {
+	got, err := Decode(strings.NewReader("# Header\n\n  w=01 r=0203  \nw=04"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []IO{{Write: []byte{1}, Read: []byte{2, 3}}, {Write: []byte{4}}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("%#v", got)
+	}
+}
+
+func TestDecode_err(t *testing.T) {
+	data := []struct {
+		in  string
+		err string
+	}{
+		{"r=01", "conntest: line 1: missing field w"},
+		{"\nw=01 x=02", "conntest: line 2: unknown field \"x=02\""},
+		{"w=0", "conntest: line 1: invalid field \"w=0\": encoding/hex: odd length hex string"},
+		{"w", "conntest: line 1: invalid field \"w\""},
+	}
+	for i, line := range data {
+		if _, err := Decode(strings.NewReader(line.in)); err == nil || err.Error() != line.err {
+			t.Fatalf("#%d: %v", i, err)
+		}
+	}
+	var io IO
+	if err := io.UnmarshalText([]byte("r=01")); err == nil || err.Error() != "conntest: missing field w" {
+		t.Fatal(err)
+	}
+}
+
+func TestParseLine_extra(t *testing.T) {
+	var io IO
+	var x string
+	extra := func(key, value string) (bool, error) {
+		if key != "x" {
+			return false, nil
+		}
+		if value == "" {
+			return true, errors.New("empty")
+		}
+		x = value
+		return true, nil
+	}
+	if err := ParseLine("w=01 x=ab r=02", &io, extra); err != nil || x != "ab" || !bytes.Equal(io.Write, []byte{1}) || !bytes.Equal(io.Read, []byte{2}) {
+		t.Fatal(io, x, err)
+	}
+	if err := ParseLine("w=01 x=", &io, extra); err == nil || err.Error() != "invalid field \"x=\": empty" {
+		t.Fatal(err)
+	}
+	if err := ParseLine("w=01 y=1", &io, extra); err == nil || err.Error() != "unknown field \"y=1\"" {
+		t.Fatal(err)
+	}
+}
+
+func TestRecord_Save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conntest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "golden.txt")
+	r := Record{Conn: &Discard{}}
+	if err := r.Tx([]byte{1}, make([]byte, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPlayback(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Tx([]byte{1}, make([]byte, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPlayback(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("expected error")
+	}
+}
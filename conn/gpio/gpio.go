@@ -9,8 +9,10 @@
 package gpio
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"periph.io/x/periph/conn/pin"
@@ -149,7 +151,8 @@ type PinOut interface {
 	Out(l Level) error
 	// PWM sets a pin as output with a specified duty cycle between 0 and Max.
 	//
-	// The pin should use the highest frequency it can use.
+	// The pin should use the highest frequency it can use. Use PWMPeriod() to
+	// use a specific frequency.
 	//
 	// Use Half for a 50% duty cycle.
 	PWM(duty int) error
@@ -169,6 +172,120 @@ type PinIO interface {
 	PWM(duty int) error
 }
 
+// PinPWM is optionally implemented by a PinOut that can output a PWM signal
+// with a specific period.
+type PinPWM interface {
+	// PWMPeriod sets a pin as output with a specified duty cycle between 0 and
+	// Max and a period as close as possible to the requested one.
+	//
+	// Returns the period actually used, which may differ from the requested
+	// one due to the resolution of the hardware.
+	PWMPeriod(duty int, period time.Duration) (time.Duration, error)
+}
+
+// PWMPeriod outputs a PWM signal on p with the specified duty cycle and
+// period.
+//
+// If p is an alias, the real pin is used. Returns an error if p doesn't
+// implement PinPWM. Returns the period actually used.
+func PWMPeriod(p PinOut, duty int, period time.Duration) (time.Duration, error) {
+	if r, ok := p.(RealPin); ok {
+		p = r.Real()
+	}
+	pp, ok := p.(PinPWM)
+	if !ok {
+		return 0, fmt.Errorf("gpio: %s doesn't support setting the pwm period", p)
+	}
+	return pp.PWMPeriod(duty, period)
+}
+
+// PWMFrequency is like PWMPeriod but uses a frequency in Hertz.
+//
+// For example, use 50 for a servo. Returns the frequency actually used.
+// Returns an error if the frequency is too low to be represented as a
+// time.Duration or if the pin doesn't report the period actually used.
+func PWMFrequency(p PinOut, duty int, hz float64) (float64, error) {
+	if !(hz > 0) {
+		return 0, fmt.Errorf("gpio: invalid frequency %gHz", hz)
+	}
+	period := float64(time.Second) / hz
+	if period >= math.MaxInt64 {
+		return 0, fmt.Errorf("gpio: frequency %gHz is too low", hz)
+	}
+	actual, err := PWMPeriod(p, duty, time.Duration(period))
+	if err != nil {
+		return 0, err
+	}
+	if actual <= 0 {
+		return 0, fmt.Errorf("gpio: %s didn't report the pwm period used", p)
+	}
+	return float64(time.Second) / float64(actual), nil
+}
+
+// PinInContext is optionally implemented by a PinIn whose wait for an edge can
+// be cancelled.
+type PinInContext interface {
+	// WaitForEdgeContext waits for the next edge like WaitForEdge(-1) but
+	// returns false as soon as ctx is done.
+	WaitForEdgeContext(ctx context.Context) bool
+}
+
+// WaitForEdgeContext waits for the next edge on p until ctx is done.
+//
+// It calls p.WaitForEdgeContext() if p implements PinInContext. Otherwise it
+// polls p.WaitForEdge() with a short timeout, so cancellation may be noticed
+// with a small delay.
+//
+// Returns true if an edge was detected, false if ctx is done.
+func WaitForEdgeContext(ctx context.Context, p PinIn) bool {
+	if pc, ok := p.(PinInContext); ok {
+		return pc.WaitForEdgeContext(ctx)
+	}
+	for ctx.Err() == nil {
+		if p.WaitForEdge(pollInterval) {
+			return true
+		}
+	}
+	return false
+}
+
+// EdgeEvent is an edge detected on an input pin.
+type EdgeEvent struct {
+	// Edge is either RisingEdge or FallingEdge.
+	Edge Edge
+	// Level is the level of the pin right after the edge.
+	Level Level
+	// Time is the time at which the edge was detected, as measured by a
+	// monotonic clock.
+	//
+	// The origin of the clock is implementation specific. Only the difference
+	// between two events from the same pin is meaningful.
+	Time time.Duration
+}
+
+func (e EdgeEvent) String() string {
+	return fmt.Sprintf("%s(%s)@%s", e.Edge, e.Level, e.Time)
+}
+
+// PinEdgeEvents is implemented by an input pin that can report the kind and
+// time of each edge detected.
+//
+// Use it to measure pulse widths or to decode quadrature and IR signals.
+type PinEdgeEvents interface {
+	// ReadEdge waits for the next edge, like WaitForEdge(), and returns it.
+	//
+	// Only the kind of edge specified in a previous In() call is returned.
+	//
+	// When the hardware or OS driver queues the edges, each call consumes
+	// exactly one edge in the order they happened and Time is the time as
+	// recorded by the kernel. Otherwise, edges may be coalesced and Time is
+	// the time at which the edge was noticed.
+	//
+	// Returns false if the timeout occurred. Specify -1 to effectively disable
+	// timeout.
+	ReadEdge(timeout time.Duration) (EdgeEvent, bool)
+}
+
 // INVALID implements PinIO and fails on all access.
 var INVALID PinIO
 
@@ -183,6 +300,10 @@ type RealPin interface {
 
 //
 
+// pollInterval is the interval used by WaitForEdgeContext() to check for
+// cancellation when the pin doesn't implement PinInContext.
+const pollInterval = 100 * time.Millisecond
+
 // errInvalidPin is returned when trying to use INVALID.
 var errInvalidPin = errors.New("gpio: invalid pin")
 
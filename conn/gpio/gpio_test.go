@@ -5,8 +5,11 @@
 package gpio
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"testing"
 	"time"
 )
@@ -23,6 +26,29 @@ func ExamplePinIn() {
 	}
 }
 
+func ExamplePinEdgeEvents() {
+	//p := gpioreg.ByNumber(6)
+	var p PinIn
+	if err := p.In(PullDown, BothEdges); err != nil {
+		log.Fatal(err)
+	}
+	r, ok := p.(PinEdgeEvents)
+	if !ok {
+		log.Fatalf("%s doesn't report edge events", p)
+	}
+	var last EdgeEvent
+	for {
+		e, ok := r.ReadEdge(-1)
+		if !ok {
+			break
+		}
+		if e.Edge == FallingEdge && last.Edge == RisingEdge {
+			fmt.Printf("%s pulse of %s\n", p, e.Time-last.Time)
+		}
+		last = e
+	}
+}
+
 func ExamplePinOut() {
 	//p := gpioreg.ByNumber(6)
 	var p PinOut
@@ -31,6 +57,17 @@ func ExamplePinOut() {
 	}
 }
 
+func ExamplePWMFrequency() {
+	//p := gpioreg.ByName("PWM0_OUT")
+	var p PinOut
+	// Center a servo: 1.5ms pulse every 20ms.
+	hz, err := PWMFrequency(p, Max*3/40, 50)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s outputs at %.2fHz\n", p, hz)
+}
+
 func TestStrings(t *testing.T) {
 	if Low.String() != "Low" || High.String() != "High" {
 		t.Fail()
@@ -41,6 +78,58 @@ func TestStrings(t *testing.T) {
 	if NoEdge.String() != "NoEdge" || Edge(100).String() != "Edge(100)" {
 		t.Fail()
 	}
+	if s := (EdgeEvent{RisingEdge, High, time.Millisecond}).String(); s != "RisingEdge(High)@1ms" {
+		t.Fatal(s)
+	}
+}
+
+func TestWaitForEdgeContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if WaitForEdgeContext(ctx, INVALID) {
+		t.Fatal("INVALID never has edges")
+	}
+	p := &edgePin{PinIn: INVALID, edges: 1}
+	if !WaitForEdgeContext(context.Background(), p) {
+		t.Fatal("expected edge")
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if WaitForEdgeContext(ctx, p) {
+		t.Fatal("unexpected edge")
+	}
+}
+
+func TestPWMPeriod(t *testing.T) {
+	if _, err := PWMPeriod(INVALID, Half, time.Millisecond); err == nil {
+		t.Fatal("INVALID doesn't support pwm")
+	}
+	p := &pwmPin{PinOut: INVALID, resolution: time.Millisecond}
+	if d, err := PWMPeriod(p, Half, 2500*time.Microsecond); err != nil || d != 2*time.Millisecond {
+		t.Fatal(d, err)
+	}
+	if p.duty != Half {
+		t.Fatal(p.duty)
+	}
+	if hz, err := PWMFrequency(p, Half, 400); err != nil || hz != 500 {
+		t.Fatal(hz, err)
+	}
+	if _, err := PWMFrequency(p, Half, 0); err == nil {
+		t.Fatal("invalid frequency")
+	}
+	if _, err := PWMFrequency(p, Half, 10000); err == nil {
+		t.Fatal("period is too short")
+	}
+	if _, err := PWMFrequency(p, Half, math.NaN()); err == nil {
+		t.Fatal("invalid frequency")
+	}
+	if _, err := PWMFrequency(p, Half, 1e-10); err == nil {
+		t.Fatal("period overflows")
+	}
+	p.unreported = true
+	if _, err := PWMFrequency(p, Half, 400); err == nil {
+		t.Fatal("period not reported")
+	}
 }
 
 func TestInvalid(t *testing.T) {
@@ -54,3 +143,40 @@ func TestInvalid(t *testing.T) {
 		t.Fail()
 	}
 }
+
+//
+
+// edgePin fakes a number of edges.
+type edgePin struct {
+	PinIn
+	edges int
+}
+
+func (e *edgePin) WaitForEdge(timeout time.Duration) bool {
+	if e.edges == 0 {
+		time.Sleep(timeout)
+		return false
+	}
+	e.edges--
+	return true
+}
+
+// pwmPin fakes a PWM with a period resolution.
+type pwmPin struct {
+	PinOut
+	resolution time.Duration
+	duty       int
+	unreported bool // Returns a 0 period
+}
+
+func (p *pwmPin) PWMPeriod(duty int, period time.Duration) (time.Duration, error) {
+	period -= period % p.resolution
+	if period == 0 {
+		return 0, errors.New("period is too short")
+	}
+	p.duty = duty
+	if p.unreported {
+		return 0, nil
+	}
+	return period, nil
+}
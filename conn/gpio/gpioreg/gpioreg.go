@@ -174,6 +174,18 @@ func RegisterAlias(alias string, number int) error {
 	return nil
 }
 
+// Clear unregisters all the pins and aliases.
+//
+// It is called by periph.Shutdown() so drivers can register them again on the
+// next periph.Init().
+func Clear() {
+	mu.Lock()
+	defer mu.Unlock()
+	byNumber = [2]map[int]gpio.PinIO{{}, {}}
+	byName = [2]map[string]gpio.PinIO{{}, {}}
+	byAlias = map[string]*pinAlias{}
+}
+
 //
 
 var (
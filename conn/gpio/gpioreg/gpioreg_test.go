@@ -204,9 +204,5 @@ func (b *basicPin) Number() int {
 }
 
 func reset() {
-	mu.Lock()
-	defer mu.Unlock()
-	byNumber = [2]map[int]gpio.PinIO{{}, {}}
-	byName = [2]map[string]gpio.PinIO{{}, {}}
-	byAlias = map[string]*pinAlias{}
+	Clear()
 }
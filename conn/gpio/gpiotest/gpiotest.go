@@ -6,6 +6,7 @@
 package gpiotest
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -92,6 +93,34 @@ func (p *Pin) WaitForEdge(timeout time.Duration) bool {
 	}
 }
 
+// WaitForEdgeContext implements gpio.PinInContext.
+func (p *Pin) WaitForEdgeContext(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case l := <-p.EdgesChan:
+		p.Out(l)
+		return true
+	}
+}
+
+// ReadEdge implements gpio.PinEdgeEvents.
+//
+// The edge is inferred from the level read from EdgesChan. Time is the time
+// elapsed since the process started.
+func (p *Pin) ReadEdge(timeout time.Duration) (gpio.EdgeEvent, bool) {
+	if !p.WaitForEdge(timeout) {
+		return gpio.EdgeEvent{}, false
+	}
+	e := gpio.EdgeEvent{Level: p.Read(), Time: time.Since(start)}
+	if e.Level == gpio.High {
+		e.Edge = gpio.RisingEdge
+	} else {
+		e.Edge = gpio.FallingEdge
+	}
+	return e, true
+}
+
 // Pull implements gpio.PinIn.
 func (p *Pin) Pull() gpio.Pull {
 	return p.P
@@ -110,4 +139,10 @@ func (p *Pin) PWM(duty int) error {
 	return errors.New("gpiotest: pwm is not implemented")
 }
 
+//
+
+var start = time.Now()
+
 var _ gpio.PinIO = &Pin{}
+var _ gpio.PinEdgeEvents = &Pin{}
+var _ gpio.PinInContext = &Pin{}
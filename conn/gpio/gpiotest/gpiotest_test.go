@@ -5,6 +5,7 @@
 package gpiotest
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -58,6 +59,42 @@ func TestPin_edge(t *testing.T) {
 	}
 }
 
+func TestPin_waitForEdgeContext(t *testing.T) {
+	p := &Pin{N: "GPIO1", Num: 1, Fn: "I2C1_SDA", EdgesChan: make(chan gpio.Level, 1)}
+	p.EdgesChan <- gpio.High
+	if !p.WaitForEdgeContext(context.Background()) {
+		t.Fatal("expected edge")
+	}
+	if p.Read() != gpio.High {
+		t.Fatal("expected High")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if p.WaitForEdgeContext(ctx) {
+		t.Fatal("unexpected edge")
+	}
+}
+
+func TestPin_readEdge(t *testing.T) {
+	p := &Pin{N: "GPIO1", Num: 1, Fn: "I2C1_SDA", EdgesChan: make(chan gpio.Level, 2)}
+	p.EdgesChan <- gpio.High
+	p.EdgesChan <- gpio.Low
+	e1, ok := p.ReadEdge(-1)
+	if !ok || e1.Edge != gpio.RisingEdge || e1.Level != gpio.High {
+		t.Fatal(e1, ok)
+	}
+	e2, ok := p.ReadEdge(time.Minute)
+	if !ok || e2.Edge != gpio.FallingEdge || e2.Level != gpio.Low {
+		t.Fatal(e2, ok)
+	}
+	if e2.Time < e1.Time {
+		t.Fatalf("%s < %s", e2.Time, e1.Time)
+	}
+	if e, ok := p.ReadEdge(time.Millisecond); ok {
+		t.Fatal(e)
+	}
+}
+
 func TestPin_fail(t *testing.T) {
 	p := &Pin{N: "GPIO1", Num: 1, Fn: "I2C1_SDA"}
 	if err := p.PWM(5); err == nil {
@@ -0,0 +1,128 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Group is a set of pins that are read and written together as a bitmask.
+//
+// Bit i of the values maps to Pins()[i].
+//
+// It is useful for parallel buses, like HD44780 LCDs or 8 bits latches.
+type Group interface {
+	fmt.Stringer
+	// Pins returns the pins in the group.
+	Pins() []PinIO
+	// In sets all the pins as input with the specified pull and no edge
+	// detection.
+	In(pull Pull) error
+	// Read returns the level of all the pins.
+	Read() uint64
+	// Out sets the pins selected by mask as output and sets their level to the
+	// corresponding bit in value. The other pins are not affected.
+	//
+	// When supported by the driver, the pins are changed at once.
+	Out(value, mask uint64) error
+}
+
+// PinGrouper is optionally implemented by a PinIO whose driver can read or
+// write multiple pins at once.
+type PinGrouper interface {
+	// Group returns a Group for pins, the first pin being the receiver.
+	//
+	// Returns nil if the pins can't be accessed at once, for example if they
+	// are not all in the same bank. In this case NewGroup() falls back to
+	// accessing the pins one by one.
+	Group(pins []PinIO) Group
+}
+
+// NewGroup returns a Group for the pins.
+//
+// If the driver of the pins implements PinGrouper and can access all the pins
+// at once, its Group is returned. Otherwise the returned Group accesses the
+// pins one by one.
+//
+// Aliases are resolved to their real pin. There can be at most 64 pins.
+func NewGroup(pins ...PinIO) (Group, error) {
+	if len(pins) == 0 || len(pins) > 64 {
+		return nil, fmt.Errorf("gpio: invalid number of pins %d", len(pins))
+	}
+	real := make([]PinIO, len(pins))
+	for i, p := range pins {
+		if p == nil {
+			return nil, errors.New("gpio: invalid nil pin")
+		}
+		if r, ok := p.(RealPin); ok {
+			p = r.Real()
+		}
+		for _, o := range real[:i] {
+			if o == p {
+				return nil, fmt.Errorf("gpio: pin %s specified twice", p)
+			}
+		}
+		real[i] = p
+	}
+	if g, ok := real[0].(PinGrouper); ok {
+		if out := g.Group(real); out != nil {
+			return out, nil
+		}
+	}
+	return &pinGroup{pins: real}, nil
+}
+
+//
+
+// pinGroup implements Group by accessing the pins one by one.
+type pinGroup struct {
+	pins []PinIO
+}
+
+func (g *pinGroup) String() string {
+	names := make([]string, len(g.pins))
+	for i, p := range g.pins {
+		names[i] = p.String()
+	}
+	return "Group(" + strings.Join(names, ", ") + ")"
+}
+
+func (g *pinGroup) Pins() []PinIO {
+	return g.pins
+}
+
+func (g *pinGroup) In(pull Pull) error {
+	for _, p := range g.pins {
+		if err := p.In(pull, NoEdge); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *pinGroup) Read() uint64 {
+	var out uint64
+	for i, p := range g.pins {
+		if p.Read() {
+			out |= 1 << uint(i)
+		}
+	}
+	return out
+}
+
+func (g *pinGroup) Out(value, mask uint64) error {
+	for i, p := range g.pins {
+		if mask&(1<<uint(i)) != 0 {
+			if err := p.Out(value&(1<<uint(i)) != 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var _ Group = &pinGroup{}
@@ -0,0 +1,140 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"log"
+	"testing"
+)
+
+func ExampleNewGroup() {
+	//d0, d1, d2, d3 := gpioreg.ByNumber(4), gpioreg.ByNumber(5), gpioreg.ByNumber(6), gpioreg.ByNumber(7)
+	var d0, d1, d2, d3 PinIO
+	g, err := NewGroup(d0, d1, d2, d3)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Write a nibble at once.
+	if err := g.Out(0xA, 0xF); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func TestNewGroup(t *testing.T) {
+	if _, err := NewGroup(); err == nil {
+		t.Fatal("no pin")
+	}
+	if _, err := NewGroup(nil); err == nil {
+		t.Fatal("nil pin")
+	}
+	p := [3]levelPin{{name: "A"}, {name: "B"}, {name: "C"}}
+	if _, err := NewGroup(&p[0], &p[0]); err == nil {
+		t.Fatal("same pin twice")
+	}
+	g, err := NewGroup(&p[0], &alias{&p[1]}, &p[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := g.String(); s != "Group(A, B, C)" {
+		t.Fatal(s)
+	}
+	if len(g.Pins()) != 3 || g.Pins()[1] != &p[1] {
+		t.Fatal("expected aliases to be resolved")
+	}
+	if err := g.Out(5, 7); err != nil {
+		t.Fatal(err)
+	}
+	if !p[0].l || p[1].l || !p[2].l {
+		t.Fatal("unexpected levels")
+	}
+	if err := g.Out(2, 2); err != nil {
+		t.Fatal(err)
+	}
+	if v := g.Read(); v != 7 {
+		t.Fatal(v)
+	}
+	if err := g.In(PullDown); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&pinGroup{pins: []PinIO{INVALID}}).Out(1, 1); err == nil {
+		t.Fatal("INVALID fails")
+	}
+}
+
+func TestNewGroup_grouper(t *testing.T) {
+	p := [2]groupedPin{}
+	p[0].name, p[1].name = "A", "B"
+	g, err := NewGroup(&p[0], &p[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.(*pinGroup); ok {
+		t.Fatal("expected the driver's group")
+	}
+	if g, err = NewGroup(&p[0], &levelPin{name: "C"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.(*pinGroup); !ok {
+		t.Fatal("expected the fallback")
+	}
+}
+
+//
+
+// levelPin remembers its level.
+type levelPin struct {
+	PinIO
+	name string
+	l    Level
+}
+
+func (p *levelPin) String() string {
+	return p.name
+}
+
+func (p *levelPin) In(pull Pull, edge Edge) error {
+	return nil
+}
+
+func (p *levelPin) Read() Level {
+	return p.l
+}
+
+func (p *levelPin) Out(l Level) error {
+	p.l = l
+	return nil
+}
+
+// groupedPin implements PinGrouper only with other groupedPin.
+type groupedPin struct {
+	levelPin
+}
+
+func (p *groupedPin) Group(pins []PinIO) Group {
+	for _, o := range pins {
+		if _, ok := o.(*groupedPin); !ok {
+			return nil
+		}
+	}
+	return &fakeGroup{pinGroup{pins: pins}}
+}
+
+type fakeGroup struct {
+	pinGroup
+}
+
+// alias is a minimal alias.
+type alias struct {
+	PinIO
+}
+
+func (a *alias) Real() PinIO {
+	return a.PinIO
+}
+
+func (a *alias) String() string {
+	return fmt.Sprintf("alias(%s)", a.PinIO)
+}
@@ -10,11 +10,16 @@
 package i2c
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	"periph.io/x/periph/conn"
 	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/pin"
 )
 
 // Bus defines the interface a concrete I²C driver must implement.
@@ -25,11 +30,80 @@ import (
 // specified. Use i2cdev.Dev as an adapter to get a conn.Conn compatible
 // object.
 type Bus interface {
+	// Tx does a transaction at the specified device address.
+	//
+	// When both w and r are empty, only the address is sent with the write bit
+	// set. This is the equivalent of a SMBus quick write and is used by Scan()
+	// to probe for a device.
 	Tx(addr uint16, w, r []byte) error
 	// Speed changes the bus speed, if supported.
 	Speed(hz int64) error
 }
 
+// BusContext is optionally implemented by a Bus whose transactions can be
+// cancelled.
+type BusContext interface {
+	// TxContext does a transaction like Tx() but gives up when ctx is done, in
+	// which case ctx.Err() is returned.
+	TxContext(ctx context.Context, addr uint16, w, r []byte) error
+}
+
+// Msg is one message of a transaction done with TxMsgs().
+//
+// Consecutive messages are separated by a repeated START condition, unless
+// NoStart is set.
+type Msg struct {
+	// Addr is the device address, either a 7 bits or a 10 bits address.
+	Addr uint16
+	// Read specifies the direction; the data is read into Buf instead of
+	// written from it.
+	Read bool
+	// Buf is the data to write or the buffer to read into. It can be empty.
+	Buf []byte
+	// TenBit specifies that Addr is a 10 bits address.
+	TenBit bool
+	// NoStart skips the START condition and the address, so Buf is sent as a
+	// continuation of the previous message.
+	NoStart bool
+	// IgnoreNAK continues the transaction even if the device doesn't
+	// acknowledge.
+	IgnoreNAK bool
+}
+
+// BusMsgs is optionally implemented by a Bus that supports transactions made
+// of an arbitrary list of messages.
+type BusMsgs interface {
+	// TxMsgs does a transaction made of msgs as a single operation unit.
+	TxMsgs(msgs []Msg) error
+}
+
+// TxMsgs does a transaction made of msgs on b.
+//
+// It's a wrapper for BusMsgs.TxMsgs() if the bus implements BusMsgs.
+// Otherwise only a write, a read or a write followed by a read to the same
+// 7 bits address without flags can be done via Bus.Tx().
+func TxMsgs(b Bus, msgs []Msg) error {
+	if m, ok := b.(BusMsgs); ok {
+		return m.TxMsgs(msgs)
+	}
+	for i := range msgs {
+		m := &msgs[i]
+		if m.TenBit || m.NoStart || m.IgnoreNAK || m.Addr != msgs[0].Addr {
+			return fmt.Errorf("i2c: %v doesn't support this sequence of messages", b)
+		}
+	}
+	switch {
+	case len(msgs) == 1 && !msgs[0].Read:
+		return b.Tx(msgs[0].Addr, msgs[0].Buf, nil)
+	case len(msgs) == 1 && len(msgs[0].Buf) != 0:
+		return b.Tx(msgs[0].Addr, nil, msgs[0].Buf)
+	case len(msgs) == 2 && !msgs[0].Read && msgs[1].Read && len(msgs[0].Buf) != 0 && len(msgs[1].Buf) != 0:
+		return b.Tx(msgs[0].Addr, msgs[0].Buf, msgs[1].Buf)
+	default:
+		return fmt.Errorf("i2c: %v doesn't support this sequence of messages", b)
+	}
+}
+
 // BusCloser is an I²C bus that can be closed.
 //
 // This interface is meant to be handled by the application and not the device
@@ -40,6 +114,34 @@ type BusCloser interface {
 	Bus
 }
 
+// AddressNACKError is an interface that should be implemented by errors that
+// indicate that no device acknowledged the address. The device may be absent,
+// busy or at a different address.
+type AddressNACKError interface {
+	AddressNACK() bool // true if the address was not acknowledged
+}
+
+// DataNACKError is an interface that should be implemented by errors that
+// indicate that the device acknowledged its address but not a byte written to
+// it, usually because it doesn't accept the command or is busy.
+type DataNACKError interface {
+	DataNACK() bool // true if a data byte was not acknowledged
+}
+
+// ArbitrationLostError is an interface that should be implemented by errors
+// that indicate that another master took control of the bus during the
+// transaction. The transaction is usually worth retrying.
+type ArbitrationLostError interface {
+	ArbitrationLost() bool // true if the bus arbitration was lost
+}
+
+// TimeoutError is an interface that should be implemented by errors that
+// indicate that the transaction didn't complete in time, for example because
+// a device stretched the clock for too long or is holding SDA low.
+type TimeoutError interface {
+	Timeout() bool // true if the transaction timed out
+}
+
 // Pins defines the pins that an I²C bus interconnect is using on the host.
 //
 // It is expected that a implementer of Bus also implement Pins but this is not
@@ -51,6 +153,49 @@ type Pins interface {
 	SDA() gpio.PinIO
 }
 
+// BusRecoverer is optionally implemented by a Bus that can recover when a
+// device holds SDA low, for example after the host was reset in the middle of
+// a transaction.
+type BusRecoverer interface {
+	// Recover frees the bus; see Recover() for details.
+	Recover() error
+}
+
+// Recover frees a bus where a device holds SDA low.
+//
+// It takes the pins as GPIO, clocks up to nine SCL pulses until the device
+// releases SDA, issues a STOP condition then restores the function of the
+// pins. Each pin must either be used as a GPIO or implement pin.PinFunc so its
+// function can be restored.
+//
+// It is meant to be used by the implementations of BusRecoverer, while no
+// transaction is in progress.
+func Recover(scl, sda gpio.PinIO) error {
+	pins := [2]gpio.PinIO{scl, sda}
+	var funcs [2]string
+	for i, p := range pins {
+		if p == nil || p == gpio.INVALID {
+			return errors.New("i2c: can't recover the bus without its SCL and SDA pins")
+		}
+		if r, ok := p.(gpio.RealPin); ok {
+			pins[i] = r.Real()
+		}
+		funcs[i] = pins[i].Function()
+		if _, ok := pins[i].(pin.PinFunc); !ok && !strings.HasPrefix(funcs[i], "In") && !strings.HasPrefix(funcs[i], "Out") {
+			return fmt.Errorf("i2c: can't recover the bus; %s can't be restored to %s", p, funcs[i])
+		}
+	}
+	err := clockOut(pins[0], pins[1])
+	for i, p := range pins {
+		if f, ok := p.(pin.PinFunc); ok {
+			if err1 := f.SetFunction(funcs[i]); err == nil {
+				err = err1
+			}
+		}
+	}
+	return err
+}
+
 // Dev is a device on a I²C bus.
 //
 // It implements conn.Conn.
@@ -72,6 +217,21 @@ func (d *Dev) Tx(w, r []byte) error {
 	return d.Bus.Tx(d.Addr, w, r)
 }
 
+// TxContext does a transaction that can be cancelled via ctx; implements
+// conn.ConnContext.
+//
+// It's a wrapper for Bus.TxContext() if the bus implements BusContext.
+// Otherwise ctx is checked before calling Bus.Tx().
+func (d *Dev) TxContext(ctx context.Context, w, r []byte) error {
+	if b, ok := d.Bus.(BusContext); ok {
+		return b.TxContext(ctx, d.Addr, w, r)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.Bus.Tx(d.Addr, w, r)
+}
+
 // Write writes to the I²C bus without reading, implementing io.Writer.
 //
 // It's a wrapper for Tx()
@@ -87,6 +247,162 @@ func (d *Dev) Duplex() conn.Duplex {
 	return conn.Half
 }
 
+// ScanMode is the method used by Scan() to probe for a device.
+type ScanMode int
+
+// Valid ScanMode values.
+const (
+	// ScanAuto uses ScanRead on the address ranges 0x30-0x37 and 0x50-0x5F and
+	// ScanQuick on the other addresses, like i2cdetect does.
+	//
+	// A quick write can corrupt some EEPROMs, which usually use these address
+	// ranges, while a read can lock up write-only devices.
+	ScanAuto ScanMode = iota
+	// ScanQuick probes with a quick write; only the address is sent.
+	ScanQuick
+	// ScanRead probes by reading one byte.
+	ScanRead
+)
+
+func (s ScanMode) String() string {
+	switch s {
+	case ScanAuto:
+		return "Auto"
+	case ScanQuick:
+		return "Quick"
+	case ScanRead:
+		return "Read"
+	default:
+		return fmt.Sprintf("ScanMode(%d)", s)
+	}
+}
+
+// ScanOpts are the options to Scan().
+type ScanOpts struct {
+	// Mode is the method used to probe each address.
+	Mode ScanMode
+	// First and Last are the inclusive range of 7 bits addresses to probe.
+	// When both are 0, the range 0x08-0x77 is used, which skips the addresses
+	// reserved by the I²C specification.
+	First uint16
+	Last  uint16
+}
+
+// Scan probes for devices on the bus and returns a Dev for each 7 bits
+// address that acknowledged, in increasing order.
+//
+// The returned Dev can be used directly or their Bus and Addr passed to a
+// device driver constructor.
+//
+// An address that doesn't acknowledge, as reported by AddressNACKError or
+// DataNACKError, is skipped. Any other error aborts the scan; the devices
+// found so far are returned along with the error.
+//
+// opts can be nil to use the default options.
+func Scan(b Bus, opts *ScanOpts) ([]Dev, error) {
+	o := ScanOpts{First: 0x08, Last: 0x77}
+	if opts != nil {
+		o.Mode = opts.Mode
+		if opts.First != 0 || opts.Last != 0 {
+			o.First = opts.First
+			o.Last = opts.Last
+		}
+	}
+	if o.Mode < ScanAuto || o.Mode > ScanRead {
+		return nil, fmt.Errorf("i2c: invalid scan mode %s", o.Mode)
+	}
+	if o.First > o.Last || o.Last > 0x7F {
+		return nil, fmt.Errorf("i2c: invalid scan range 0x%02X-0x%02X", o.First, o.Last)
+	}
+	var out []Dev
+	var buf [1]byte
+	for addr := o.First; addr <= o.Last; addr++ {
+		mode := o.Mode
+		if mode == ScanAuto {
+			mode = ScanQuick
+			if (addr >= 0x30 && addr <= 0x37) || (addr >= 0x50 && addr <= 0x5F) {
+				mode = ScanRead
+			}
+		}
+		var err error
+		if mode == ScanRead {
+			err = b.Tx(addr, nil, buf[:])
+		} else {
+			err = b.Tx(addr, nil, nil)
+		}
+		if err == nil {
+			out = append(out, Dev{Bus: b, Addr: addr})
+		} else if !isNACK(err) {
+			return out, fmt.Errorf("i2c: scan 0x%02X: %v", addr, err)
+		}
+	}
+	return out, nil
+}
+
 //
 
+// isNACK returns true if err reports that the device didn't acknowledge.
+//
+// A probe doesn't write any data, so a data NACK can only come from the
+// address; some adapters, like the bcm2835 one, don't distinguish both.
+func isNACK(err error) bool {
+	if e, ok := err.(AddressNACKError); ok && e.AddressNACK() {
+		return true
+	}
+	e, ok := err.(DataNACKError)
+	return ok && e.DataNACK()
+}
+
+// recoveryHalfCycle is the half period of the clock used by Recover(),
+// 100kHz.
+const recoveryHalfCycle = 5 * time.Microsecond
+
+// clockOut does the actual bus recovery.
+//
+// A line is set high by releasing it, relying on the pull up, so a device
+// stretching the clock doesn't fight with the host.
+func clockOut(scl, sda gpio.PinIO) error {
+	release := func(p gpio.PinIO) error {
+		return p.In(gpio.PullUp, gpio.NoEdge)
+	}
+	if err := release(sda); err != nil {
+		return err
+	}
+	if err := release(scl); err != nil {
+		return err
+	}
+	for i := 0; i < 9 && sda.Read() == gpio.Low; i++ {
+		if err := scl.Out(gpio.Low); err != nil {
+			return err
+		}
+		time.Sleep(recoveryHalfCycle)
+		if err := release(scl); err != nil {
+			return err
+		}
+		time.Sleep(recoveryHalfCycle)
+	}
+	// STOP condition: SDA goes from low to high while SCL is high.
+	if err := scl.Out(gpio.Low); err != nil {
+		return err
+	}
+	time.Sleep(recoveryHalfCycle)
+	if err := sda.Out(gpio.Low); err != nil {
+		return err
+	}
+	time.Sleep(recoveryHalfCycle)
+	if err := release(scl); err != nil {
+		return err
+	}
+	time.Sleep(recoveryHalfCycle)
+	if err := release(sda); err != nil {
+		return err
+	}
+	time.Sleep(recoveryHalfCycle)
+	if sda.Read() == gpio.Low {
+		return errors.New("i2c: SDA is still held low after recovery")
+	}
+	return nil
+}
+
 var _ conn.Conn = &Dev{}
+var _ conn.ConnContext = &Dev{}
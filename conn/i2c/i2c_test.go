@@ -6,12 +6,15 @@ package i2c
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"testing"
 
 	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
 )
 
 func ExampleDev() {
@@ -75,6 +78,31 @@ func TestDevTx(t *testing.T) {
 	}
 }
 
+func TestDevTxContext(t *testing.T) {
+	b := &fakeBus{r: []byte{1}}
+	d := Dev{b, 12}
+	r := make([]byte, 1)
+	if err := d.TxContext(context.Background(), []byte{3}, r); err != nil {
+		t.Fatal(err)
+	}
+	if r[0] != 1 || b.addr != 12 {
+		t.Fatal(r, b.addr)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.TxContext(ctx, []byte{4}, nil); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.w, []byte{3}) {
+		t.Fatal(b.w)
+	}
+	bc := &fakeBusContext{}
+	d = Dev{bc, 13}
+	if err := d.TxContext(ctx, []byte{4}, nil); err != context.Canceled || bc.addr != 13 {
+		t.Fatal(err, bc.addr)
+	}
+}
+
 func TestDevWrite(t *testing.T) {
 	b := &fakeBus{}
 	d := Dev{b, 12}
@@ -106,6 +134,184 @@ func TestDevWriteErr(t *testing.T) {
 	}
 }
 
+func TestTxMsgs(t *testing.T) {
+	b := &fakeBus{r: []byte{1, 2}}
+	w := []byte{3}
+	r := make([]byte, 2)
+	if err := TxMsgs(b, []Msg{{Addr: 12, Buf: w}, {Addr: 12, Read: true, Buf: r}}); err != nil {
+		t.Fatal(err)
+	}
+	if b.addr != 12 || !bytes.Equal(b.w, w) || !bytes.Equal(r, []byte{1, 2}) {
+		t.Fatal(b.addr, b.w, r)
+	}
+	b = &fakeBus{r: []byte{4}}
+	if err := TxMsgs(b, []Msg{{Addr: 13, Read: true, Buf: r[:1]}}); err != nil || r[0] != 4 || b.addr != 13 {
+		t.Fatal(err, r, b.addr)
+	}
+	if err := TxMsgs(b, []Msg{{Addr: 14, Buf: w}}); err != nil || b.addr != 14 {
+		t.Fatal(err, b.addr)
+	}
+	invalid := [][]Msg{
+		nil,
+		{{Addr: 12, Buf: w, TenBit: true}},
+		{{Addr: 12, Buf: w}, {Addr: 12, Buf: w, NoStart: true}},
+		{{Addr: 12, Buf: w, IgnoreNAK: true}},
+		{{Addr: 12, Buf: w}, {Addr: 13, Read: true, Buf: r}},
+		{{Addr: 12, Buf: w}, {Addr: 12, Buf: w}},
+		{{Addr: 12, Read: true}},
+		{{Addr: 12, Buf: w}, {Addr: 12, Read: true, Buf: r}, {Addr: 12, Buf: w}},
+	}
+	for i, msgs := range invalid {
+		if err := TxMsgs(b, msgs); err == nil {
+			t.Fatalf("#%d: expected error", i)
+		}
+	}
+	m := &fakeBusMsgs{}
+	msgs := []Msg{{Addr: 0x150, TenBit: true, Buf: w}}
+	if err := TxMsgs(m, msgs); err != nil || len(m.msgs) != 1 || m.msgs[0].Addr != 0x150 {
+		t.Fatal(err, m.msgs)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	b := &stuckBus{held: 3}
+	scl := &stuckPin{Pin: gpiotest.Pin{N: "SCL", Fn: "I2C1_SCL"}, bus: b, scl: true}
+	sda := &stuckPin{Pin: gpiotest.Pin{N: "SDA", Fn: "I2C1_SDA"}, bus: b}
+	if err := Recover(scl, sda); err != nil {
+		t.Fatal(err)
+	}
+	if b.pulses != 4 {
+		t.Fatal(b.pulses)
+	}
+	// The last operations must be a STOP condition.
+	stop := []string{"SCL=L", "SDA=L", "SCL=H", "SDA=H"}
+	if l := b.log[len(b.log)-4:]; fmt.Sprint(l) != fmt.Sprint(stop) {
+		t.Fatal(b.log)
+	}
+	if scl.fn != "I2C1_SCL" || sda.fn != "I2C1_SDA" {
+		t.Fatal(scl.fn, sda.fn)
+	}
+}
+
+func TestRecover_stuck(t *testing.T) {
+	b := &stuckBus{held: 100}
+	scl := &stuckPin{Pin: gpiotest.Pin{N: "SCL", Fn: "I2C1_SCL"}, bus: b, scl: true}
+	sda := &stuckPin{Pin: gpiotest.Pin{N: "SDA", Fn: "I2C1_SDA"}, bus: b}
+	if err := Recover(scl, sda); err == nil || err.Error() != "i2c: SDA is still held low after recovery" {
+		t.Fatal(err)
+	}
+	// Nine pulses then the STOP condition.
+	if b.pulses != 10 {
+		t.Fatal(b.pulses)
+	}
+	if scl.fn != "I2C1_SCL" || sda.fn != "I2C1_SDA" {
+		t.Fatal(scl.fn, sda.fn)
+	}
+}
+
+func TestRecover_invalid(t *testing.T) {
+	if err := Recover(gpio.INVALID, &gpiotest.Pin{N: "SDA", Fn: "In/High"}); err == nil {
+		t.Fatal("expected error")
+	}
+	// The function of these pins can't be restored.
+	err := Recover(&gpiotest.Pin{N: "SCL", Fn: "I2C1_SCL"}, &gpiotest.Pin{N: "SDA", Fn: "I2C1_SDA"})
+	if err == nil || err.Error() != "i2c: can't recover the bus; SCL(0) can't be restored to I2C1_SCL" {
+		t.Fatal(err)
+	}
+	// Pins used as GPIO don't need to be restored.
+	if err := Recover(&gpiotest.Pin{N: "SCL", Fn: "Out/High"}, &gpiotest.Pin{N: "SDA", Fn: "In/High"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	b := &scanBus{present: map[uint16]bool{0x08: true, 0x50: true, 0x76: true, 0x78: true}}
+	devs, err := Scan(b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devs) != 3 || devs[0].Addr != 0x08 || devs[1].Addr != 0x50 || devs[2].Addr != 0x76 || devs[2].Bus != b {
+		t.Fatal(devs)
+	}
+	if len(b.probes) != 0x70 {
+		t.Fatal(len(b.probes))
+	}
+	for addr, read := range b.probes {
+		expected := (addr >= 0x30 && addr <= 0x37) || (addr >= 0x50 && addr <= 0x5F)
+		if read != expected {
+			t.Fatalf("0x%02X: read=%t", addr, read)
+		}
+	}
+}
+
+func TestScan_opts(t *testing.T) {
+	b := &scanBus{present: map[uint16]bool{0x03: true, 0x50: true}}
+	devs, err := Scan(b, &ScanOpts{Mode: ScanQuick, First: 0x03, Last: 0x50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devs) != 2 || devs[0].Addr != 0x03 || devs[1].Addr != 0x50 {
+		t.Fatal(devs)
+	}
+	if len(b.probes) != 0x4E || b.probes[0x50] {
+		t.Fatal(b.probes)
+	}
+	b = &scanBus{}
+	if _, err := Scan(b, &ScanOpts{Mode: ScanRead}); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.probes) != 0x70 || !b.probes[0x08] || !b.probes[0x77] {
+		t.Fatal(b.probes)
+	}
+}
+
+func TestScan_dataNACK(t *testing.T) {
+	b := &scanBus{present: map[uint16]bool{0x50: true}, err: dataNACKError("nack")}
+	devs, err := Scan(b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devs) != 1 || devs[0].Addr != 0x50 {
+		t.Fatal(devs)
+	}
+}
+
+func TestScan_err(t *testing.T) {
+	b := &scanBus{present: map[uint16]bool{0x08: true}, err: errors.New("oops")}
+	devs, err := Scan(b, nil)
+	if err == nil || err.Error() != "i2c: scan 0x09: oops" {
+		t.Fatal(err)
+	}
+	if len(devs) != 1 || devs[0].Addr != 0x08 {
+		t.Fatal(devs)
+	}
+	if len(b.probes) != 2 {
+		t.Fatal(b.probes)
+	}
+}
+
+func TestScan_invalid(t *testing.T) {
+	data := []ScanOpts{
+		{Mode: ScanMode(3)},
+		{First: 0x10, Last: 0x08},
+		{First: 0x08, Last: 0x80},
+	}
+	for i, line := range data {
+		if _, err := Scan(&scanBus{}, &line); err == nil {
+			t.Fatalf("%d: expected error", i)
+		}
+	}
+}
+
+func TestScanMode_String(t *testing.T) {
+	if s := ScanRead.String(); s != "Read" {
+		t.Fatal(s)
+	}
+	if s := ScanMode(10).String(); s != "ScanMode(10)" {
+		t.Fatal(s)
+	}
+}
+
 //
 
 type fakeBus struct {
@@ -135,3 +341,106 @@ func (f *fakeBus) Speed(hz int64) error {
 	f.speed = hz
 	return f.err
 }
+
+type fakeBusContext struct {
+	fakeBus
+}
+
+func (f *fakeBusContext) TxContext(ctx context.Context, addr uint16, w, r []byte) error {
+	f.addr = addr
+	return ctx.Err()
+}
+
+type fakeBusMsgs struct {
+	fakeBus
+	msgs []Msg
+}
+
+func (f *fakeBusMsgs) TxMsgs(msgs []Msg) error {
+	f.msgs = msgs
+	return nil
+}
+
+// scanBus acknowledges the addresses in present and records the probes done,
+// true for a read. The other addresses return err, or an AddressNACKError
+// when nil.
+type scanBus struct {
+	present map[uint16]bool
+	probes  map[uint16]bool
+	err     error
+}
+
+func (s *scanBus) Tx(addr uint16, w, r []byte) error {
+	if s.probes == nil {
+		s.probes = map[uint16]bool{}
+	}
+	s.probes[addr] = len(r) != 0
+	if len(w) != 0 || len(r) > 1 {
+		return errors.New("unexpected probe")
+	}
+	if !s.present[addr] {
+		if s.err != nil {
+			return s.err
+		}
+		return addressNACKError("nack")
+	}
+	return nil
+}
+
+func (s *scanBus) Speed(hz int64) error {
+	return nil
+}
+
+type addressNACKError string
+
+func (e addressNACKError) Error() string     { return string(e) }
+func (e addressNACKError) AddressNACK() bool { return true }
+
+type dataNACKError string
+
+func (e dataNACKError) Error() string  { return string(e) }
+func (e dataNACKError) DataNACK() bool { return true }
+
+// stuckBus simulates a device holding SDA low for a number of SCL pulses.
+type stuckBus struct {
+	held   int
+	pulses int
+	log    []string
+}
+
+// stuckPin is a pin of stuckBus that implements pin.PinFunc.
+type stuckPin struct {
+	gpiotest.Pin
+	bus *stuckBus
+	scl bool
+	fn  string
+}
+
+func (s *stuckPin) In(pull gpio.Pull, edge gpio.Edge) error {
+	s.bus.log = append(s.bus.log, s.N+"=H")
+	return s.Pin.In(pull, edge)
+}
+
+func (s *stuckPin) Out(l gpio.Level) error {
+	if l {
+		s.bus.log = append(s.bus.log, s.N+"=H")
+	} else {
+		s.bus.log = append(s.bus.log, s.N+"=L")
+		if s.scl {
+			s.bus.pulses++
+		}
+	}
+	return s.Pin.Out(l)
+}
+
+func (s *stuckPin) Read() gpio.Level {
+	if !s.scl && s.bus.pulses < s.bus.held {
+		return gpio.Low
+	}
+	return s.Pin.Read()
+}
+
+func (s *stuckPin) SetFunction(f string) error {
+	s.fn = f
+	return nil
+}
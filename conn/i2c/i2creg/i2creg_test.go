@@ -191,11 +191,7 @@ func fakeBuser() (i2c.BusCloser, error) {
 }
 
 func reset() {
-	mu.Lock()
-	defer mu.Unlock()
-	byName = map[string]*Ref{}
-	byNumber = map[int]*Ref{}
-	byAlias = map[string]*Ref{}
+	Clear()
 }
 
 type fakeBus struct {
@@ -0,0 +1,59 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package i2ctest
+
+import (
+	"periph.io/x/periph/conn/conntest"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/i2c"
+)
+
+// Fault implements i2c.Bus and injects faults in the transactions done on Bus.
+//
+// See conntest.Faults for the faults that can be injected.
+type Fault struct {
+	conntest.Faults
+	Bus i2c.Bus
+}
+
+func (f *Fault) String() string {
+	return "fault"
+}
+
+// Tx implements i2c.Bus.
+func (f *Fault) Tx(addr uint16, w, r []byte) error {
+	if err := f.Inject(); err != nil {
+		return err
+	}
+	if err := f.Bus.Tx(addr, w, r); err != nil {
+		return err
+	}
+	f.Corrupt(r)
+	return nil
+}
+
+// Speed implements i2c.Bus.
+func (f *Fault) Speed(hz int64) error {
+	return f.Bus.Speed(hz)
+}
+
+// SCL implements i2c.Pins.
+func (f *Fault) SCL() gpio.PinIO {
+	if p, ok := f.Bus.(i2c.Pins); ok {
+		return p.SCL()
+	}
+	return gpio.INVALID
+}
+
+// SDA implements i2c.Pins.
+func (f *Fault) SDA() gpio.PinIO {
+	if p, ok := f.Bus.(i2c.Pins); ok {
+		return p.SDA()
+	}
+	return gpio.INVALID
+}
+
+var _ i2c.Bus = &Fault{}
+var _ i2c.Pins = &Fault{}
@@ -0,0 +1,63 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package i2ctest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/conntest"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/i2c"
+)
+
+func TestFault(t *testing.T) {
+	m := &RegisterMap{AutoIncrement: true}
+	m.Regs[0xD0] = 0x60
+	b := &Bus{Devices: map[uint16]Device{0x76: m}, SCLPin: &gpiotest.Pin{N: "SCL"}}
+	errFoo := errors.New("foo")
+	f := &Fault{Faults: conntest.Faults{Every: 3, Err: errFoo}, Bus: b}
+	if s := f.String(); s != "fault" {
+		t.Fatal(s)
+	}
+	if err := f.Speed(100000); err != nil {
+		t.Fatal(err)
+	}
+	if p := f.SCL(); p.Name() != "SCL" {
+		t.Fatal(p)
+	}
+	if p := f.SDA(); p != nil {
+		t.Fatal(p)
+	}
+	d := i2c.Dev{Bus: f, Addr: 0x76}
+	var id [1]byte
+	for i, expected := range []error{nil, nil, errFoo, nil} {
+		id[0] = 0
+		err := d.Tx([]byte{0xD0}, id[:])
+		if err != expected {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if err == nil && id[0] != 0x60 {
+			t.Fatalf("%d: 0x%02X", i, id[0])
+		}
+	}
+	// Errors from the wrapped bus are forwarded as-is.
+	if err := f.Tx(0x77, nil, nil); err == nil || err == errFoo {
+		t.Fatal(err)
+	}
+}
+
+func TestFault_bitFlip(t *testing.T) {
+	m := &RegisterMap{}
+	f := &Fault{Faults: conntest.Faults{BitFlip: 1, Delay: time.Microsecond}, Bus: &Bus{Devices: map[uint16]Device{0x10: m}}}
+	var r [1]byte
+	if err := f.Tx(0x10, []byte{0}, r[:]); err != nil {
+		t.Fatal(err)
+	}
+	if r[0] == 0 || r[0]&(r[0]-1) != 0 {
+		t.Fatalf("0x%02X", r[0])
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package i2ctest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"periph.io/x/periph/conn/conntest"
+)
+
+// The golden file format is the same as the one of package conntest, with the
+// device address as the first field, in hex. For example, reading the chip ID
+// of a BME280 is:
+//
+//	addr=76 w=d0 r=60
+
+// MarshalText implements encoding.TextMarshaler using the golden file format.
+func (i IO) MarshalText() ([]byte, error) {
+	b, _ := conntest.IO{Write: i.Write, Read: i.Read}.MarshalText()
+	return []byte(fmt.Sprintf("addr=%02x %s", i.Addr, b)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using the golden file
+// format.
+func (i *IO) UnmarshalText(text []byte) error {
+	if err := i.parse(string(text)); err != nil {
+		return errors.New("i2ctest: " + err.Error())
+	}
+	return nil
+}
+
+// Encode writes ops in the golden file format.
+func Encode(w io.Writer, ops []IO) error {
+	for _, op := range ops {
+		b, _ := op.MarshalText()
+		if _, err := fmt.Fprintf(w, "%s\n", b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode reads ops in the golden file format.
+func Decode(r io.Reader) ([]IO, error) {
+	var ops []IO
+	err := conntest.DecodeLines(r, func(line string) error {
+		var op IO
+		if err := op.parse(line); err != nil {
+			return err
+		}
+		ops = append(ops, op)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("i2ctest: " + err.Error())
+	}
+	return ops, nil
+}
+
+// LoadPlayback returns a Playback with the operations read from the golden
+// file at path.
+func LoadPlayback(path string) (*Playback, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ops, err := Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return &Playback{Ops: ops}, nil
+}
+
+// Save writes the recorded operations to the golden file at path.
+func (r *Record) Save(path string) error {
+	r.Lock()
+	defer r.Unlock()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Encode(f, r.Ops); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+//
+
+// parse parses line with conntest.ParseLine() and handles the addr field.
+func (i *IO) parse(line string) error {
+	*i = IO{}
+	found := false
+	var op conntest.IO
+	err := conntest.ParseLine(line, &op, func(key, value string) (bool, error) {
+		if key != "addr" {
+			return false, nil
+		}
+		a, err := strconv.ParseUint(value, 16, 16)
+		i.Addr = uint16(a)
+		found = true
+		return true, err
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("missing field addr")
+	}
+	i.Write = op.Write
+	i.Read = op.Read
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package i2ctest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	ops := []IO{
+		{Addr: 0x76, Write: []byte{0xD0}, Read: []byte{0x60}},
+		{Addr: 0x3C, Write: []byte{1, 2, 3}},
+	}
+	b := bytes.Buffer{}
+	if err := Encode(&b, ops); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); s != "addr=76 w=d0 r=60\naddr=3c w=010203\n" {
+		t.Fatalf("%q", s)
+	}
+	got, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, ops) {
+		t.Fatalf("%#v", got)
+	}
+}
+
+func TestDecode_err(t *testing.T) {
+	data := []struct {
+		in  string
+		err string
+	}{
+		{"w=01", "i2ctest: line 1: missing field addr"},
+		{"# comment\naddr=10000 w=01", "i2ctest: line 2: invalid field \"addr=10000\": strconv.ParseUint: parsing \"10000\": value out of range"},
+		{"addr=10 w=01 x=02", "i2ctest: line 1: unknown field \"x=02\""},
+		{"addr", "i2ctest: line 1: invalid field \"addr\""},
+	}
+	for i, line := range data {
+		if _, err := Decode(strings.NewReader(line.in)); err == nil || err.Error() != line.err {
+			t.Fatalf("#%d: %v", i, err)
+		}
+	}
+	var io IO
+	if err := io.UnmarshalText([]byte("w=01")); err == nil || err.Error() != "i2ctest: missing field addr" {
+		t.Fatal(err)
+	}
+}
+
+func TestRecord_Save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i2ctest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "golden.txt")
+	m := &RegisterMap{}
+	m.Regs[0xD0] = 0x60
+	r := Record{Bus: &Bus{Devices: map[uint16]Device{0x76: m}}}
+	v := make([]byte, 1)
+	if err := r.Tx(0x76, []byte{0xD0}, v); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPlayback(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v[0] = 0
+	if err := p.Tx(0x76, []byte{0xD0}, v); err != nil {
+		t.Fatal(err)
+	}
+	if v[0] != 0x60 {
+		t.Fatal(v)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
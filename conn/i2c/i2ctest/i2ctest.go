@@ -147,7 +147,152 @@ func (p *Playback) SDA() gpio.PinIO {
 	return p.SDAPin
 }
 
+// Device is a fake I²C device that can be attached to a Bus.
+type Device interface {
+	// Tx is called for each transaction addressed to the device.
+	Tx(w, r []byte) error
+}
+
+// Bus implements i2c.BusCloser and forwards each transaction to the Device
+// attached at the address.
+//
+// Contrary to Playback, the transactions are not required to happen in a
+// specific order; the test verifies the behavior of the driver against a model
+// of the device.
+//
+// A transaction to an address without a device fails with an error
+// implementing i2c.AddressNACKError, as if the address was not acknowledged.
+type Bus struct {
+	sync.Mutex
+	Devices map[uint16]Device
+	SDAPin  gpio.PinIO
+	SCLPin  gpio.PinIO
+}
+
+func (b *Bus) String() string {
+	return "bus"
+}
+
+// Close implements i2c.BusCloser.
+func (b *Bus) Close() error {
+	return nil
+}
+
+// Tx implements i2c.Bus.
+func (b *Bus) Tx(addr uint16, w, r []byte) error {
+	b.Lock()
+	d := b.Devices[addr]
+	b.Unlock()
+	if d == nil {
+		return addressNACKError(fmt.Sprintf("i2ctest: no device at address 0x%02X", addr))
+	}
+	return d.Tx(w, r)
+}
+
+// Speed implements i2c.Bus.
+func (b *Bus) Speed(hz int64) error {
+	return nil
+}
+
+// SCL implements i2c.Pins.
+func (b *Bus) SCL() gpio.PinIO {
+	return b.SCLPin
+}
+
+// SDA implements i2c.Pins.
+func (b *Bus) SDA() gpio.PinIO {
+	return b.SDAPin
+}
+
+// addressNACKError implements error and i2c.AddressNACKError.
+type addressNACKError string
+
+func (e addressNACKError) Error() string     { return string(e) }
+func (e addressNACKError) AddressNACK() bool { return true }
+
+// RegisterMap implements Device and simulates a device exposing 8 bits
+// registers, which is the case of most I²C devices.
+//
+// The first byte written in a transaction is the register address. The
+// following bytes are written to the registers starting at this address. The
+// bytes read are the registers starting at the current register address.
+//
+// When AutoIncrement is true, the register address is incremented after each
+// byte read or written, wrapping around at 0xFF. Otherwise all the bytes are
+// read from or written to the same register.
+//
+// When WritePairs is true, the bytes written are instead pairs of register
+// address and value, like the BME280.
+type RegisterMap struct {
+	sync.Mutex
+	// Regs is the content of the registers.
+	Regs [256]byte
+	// ReadOnly is the mask of the bits that can't be written for each register.
+	ReadOnly      [256]byte
+	AutoIncrement bool
+	WritePairs    bool
+	// OnWrite, if set, is called after a register is written. value is the byte
+	// written by the driver, before ReadOnly is applied. Use it to simulate
+	// side effects, like a reset or starting a measurement.
+	//
+	// It is called with the RegisterMap locked, so it can access Regs directly.
+	OnWrite func(reg uint8, value byte)
+	// OnRead, if set, is called after a register is read. Use it to simulate
+	// side effects, like clearing a status bit or updating a measurement.
+	//
+	// It is called with the RegisterMap locked, so it can access Regs directly.
+	OnRead func(reg uint8)
+
+	reg uint8 // Current register address.
+}
+
+// Tx implements Device.
+func (m *RegisterMap) Tx(w, r []byte) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.WritePairs && len(w) > 1 {
+		if len(w)%2 != 0 {
+			return fmt.Errorf("i2ctest: expected register and value pairs, got %d bytes", len(w))
+		}
+		for i := 0; i < len(w); i += 2 {
+			m.reg = w[i]
+			m.write(w[i+1])
+		}
+	} else if len(w) != 0 {
+		m.reg = w[0]
+		for _, b := range w[1:] {
+			m.write(b)
+			if m.AutoIncrement {
+				m.reg++
+			}
+		}
+	}
+	for i := range r {
+		r[i] = m.Regs[m.reg]
+		if m.OnRead != nil {
+			m.OnRead(m.reg)
+		}
+		if m.AutoIncrement {
+			m.reg++
+		}
+	}
+	return nil
+}
+
+// write writes b to the current register.
+func (m *RegisterMap) write(b byte) {
+	ro := m.ReadOnly[m.reg]
+	m.Regs[m.reg] = m.Regs[m.reg]&ro | b&^ro
+	if m.OnWrite != nil {
+		m.OnWrite(m.reg, b)
+	}
+}
+
 var _ i2c.Bus = &Record{}
 var _ i2c.Pins = &Record{}
 var _ i2c.Bus = &Playback{}
 var _ i2c.Pins = &Playback{}
+var _ i2c.BusCloser = &Bus{}
+var _ i2c.Pins = &Bus{}
+var _ Device = &RegisterMap{}
+var _ i2c.AddressNACKError = addressNACKError("")
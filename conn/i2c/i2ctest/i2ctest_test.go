@@ -5,12 +5,39 @@
 package i2ctest
 
 import (
+	"bytes"
+	"fmt"
+	"log"
 	"testing"
 
 	"periph.io/x/periph/conn/gpio"
 	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/i2c"
 )
 
+func ExampleRegisterMap() {
+	// Simulates a device with a chip ID register at 0xD0, a control register at
+	// 0xF4 and a soft reset register at 0xE0.
+	m := &RegisterMap{AutoIncrement: true}
+	m.Regs[0xD0] = 0x60
+	m.ReadOnly[0xD0] = 0xFF
+	m.OnWrite = func(reg uint8, value byte) {
+		if reg == 0xE0 && value == 0xB6 {
+			m.Regs[0xF4] = 0
+		}
+	}
+	b := &Bus{Devices: map[uint16]Device{0x76: m}}
+
+	// Use the bus like a real one.
+	d := i2c.Dev{Bus: b, Addr: 0x76}
+	var id [1]byte
+	if err := d.Tx([]byte{0xD0}, id[:]); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("0x%02X\n", id[0])
+	// Output: 0x60
+}
+
 func TestRecord_empty(t *testing.T) {
 	r := Record{}
 	if s := r.String(); s != "record" {
@@ -146,3 +173,119 @@ func TestRecord_Playback(t *testing.T) {
 		t.Fatal("Playback.Ops is empty")
 	}
 }
+
+func TestBus(t *testing.T) {
+	m := &RegisterMap{}
+	b := Bus{
+		Devices: map[uint16]Device{0x10: m},
+		SDAPin:  &gpiotest.Pin{N: "DA"},
+		SCLPin:  &gpiotest.Pin{N: "CL"},
+	}
+	if s := b.String(); s != "bus" {
+		t.Fatal(s)
+	}
+	if err := b.Speed(100); err != nil {
+		t.Fatal(err)
+	}
+	if n := b.SDA().Name(); n != "DA" {
+		t.Fatal(n)
+	}
+	if n := b.SCL().Name(); n != "CL" {
+		t.Fatal(n)
+	}
+	if err := b.Tx(0x11, []byte{0}, nil); err == nil || err.Error() != "i2ctest: no device at address 0x11" {
+		t.Fatal(err)
+	} else if e, ok := err.(i2c.AddressNACKError); !ok || !e.AddressNACK() {
+		t.Fatal(err)
+	}
+	if err := b.Tx(0x10, []byte{1, 2}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if m.Regs[1] != 2 {
+		t.Fatal(m.Regs[1])
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterMap(t *testing.T) {
+	m := &RegisterMap{AutoIncrement: true}
+	m.ReadOnly[0x11] = 0xF0
+	m.Regs[0x11] = 0x50
+	var written []byte
+	m.OnWrite = func(reg uint8, value byte) {
+		written = append(written, reg, value)
+	}
+	m.OnRead = func(reg uint8) {
+		if reg == 0xFF {
+			// Clear on read.
+			m.Regs[0xFF] = 0
+		}
+	}
+	if err := m.Tx([]byte{0x10, 1, 0xA2, 3}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.Regs[0x10:0x13], []byte{1, 0x52, 3}) {
+		t.Fatalf("%#v", m.Regs[0x10:0x13])
+	}
+	if !bytes.Equal(written, []byte{0x10, 1, 0x11, 0xA2, 0x12, 3}) {
+		t.Fatalf("%#v", written)
+	}
+	// Read continues at the current register.
+	r := make([]byte, 1)
+	if err := m.Tx(nil, r); err != nil {
+		t.Fatal(err)
+	}
+	if r[0] != 0 || m.reg != 0x14 {
+		t.Fatal(r, m.reg)
+	}
+	// Wraps around.
+	m.Regs[0xFF] = 0xAA
+	m.Regs[0] = 0xBB
+	r = make([]byte, 3)
+	if err := m.Tx([]byte{0xFF}, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, []byte{0xAA, 0xBB, 0}) || m.Regs[0xFF] != 0 {
+		t.Fatalf("%#v", r)
+	}
+}
+
+func TestRegisterMap_noAutoIncrement(t *testing.T) {
+	m := &RegisterMap{}
+	if err := m.Tx([]byte{0x20, 1, 2, 3}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if m.Regs[0x20] != 3 || m.Regs[0x21] != 0 {
+		t.Fatalf("%#v", m.Regs[0x20:0x22])
+	}
+	m.Regs[0x21] = 4
+	r := make([]byte, 2)
+	if err := m.Tx([]byte{0x20}, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r, []byte{3, 3}) {
+		t.Fatalf("%#v", r)
+	}
+}
+
+func TestRegisterMap_writePairs(t *testing.T) {
+	m := &RegisterMap{WritePairs: true}
+	if err := m.Tx([]byte{0xF4, 1, 0xF2, 2}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if m.Regs[0xF4] != 1 || m.Regs[0xF2] != 2 {
+		t.Fatalf("%#v", m.Regs[0xF2:0xF5])
+	}
+	if m.Tx([]byte{0xF4, 1, 0xF2}, nil) == nil {
+		t.Fatal("odd number of bytes")
+	}
+	r := make([]byte, 1)
+	if err := m.Tx([]byte{0xF4}, r); err != nil {
+		t.Fatal(err)
+	}
+	if r[0] != 1 {
+		t.Fatal(r)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package smbus
+
+// CheckPEC verifies that the last byte of the buffer contains the PEC of the
+// previous bytes.
+func CheckPEC(buf []byte) bool {
+	if len(buf) == 0 {
+		return false
+	}
+	return CalcPEC(buf[:len(buf)-1]) == buf[len(buf)-1]
+}
+
+// CalcPEC calculates the SMBus Packet Error Code across the buffer of bytes
+// and returns it.
+//
+// The PEC is a CRC-8 with the polynomial x^8+x^2+x+1 (0x07), as described in
+// section 6.4 of the SMBus specification 3.0. It covers every byte of the
+// transaction, including the address bytes.
+func CalcPEC(buf []byte) byte {
+	var crc byte
+	for _, b := range buf {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,27 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package smbus
+
+import "testing"
+
+func TestCheckPEC(t *testing.T) {
+	a := []byte("123456789")
+	c := CalcPEC(a)
+	if c != 0xF4 {
+		t.Fatalf("0x%02X", c)
+	}
+	b := append([]byte{}, a...)
+	b = append(b, c)
+	if !CheckPEC(b) {
+		t.FailNow()
+	}
+	b[len(b)-1]++
+	if CheckPEC(b) {
+		t.FailNow()
+	}
+	if CheckPEC(nil) {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package smbus implements the System Management Bus protocol on top of an
+// I²C bus.
+//
+// Many devices like fuel gauges, power management ICs and fan controllers use
+// SMBus transactions: byte data, word data, block data with a length prefix
+// and process call, optionally protected by a Packet Error Code (PEC).
+//
+// When the bus implements Native, like host/sysfs.I2C does via the kernel
+// I2C_SMBUS ioctl, the transactions are done natively. Otherwise they are
+// emulated over i2c.Bus.Tx().
+//
+// The specification is at http://smbus.org/specs/SMBus_3_0_20141220.pdf and
+// the Linux kernel documentation at
+// https://www.kernel.org/doc/Documentation/i2c/smbus-protocol.
+package smbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"periph.io/x/periph/conn/i2c"
+)
+
+// Protocol is a SMBus transaction type.
+//
+// The values match the transaction sizes used by the Linux kernel.
+type Protocol uint32
+
+// Valid Protocol values.
+const (
+	Quick     Protocol = 0 // Only the address and the direction bit
+	Byte      Protocol = 1 // One byte without a command
+	ByteData  Protocol = 2 // One byte after a command
+	WordData  Protocol = 3 // A 16 bits little endian word after a command
+	ProcCall  Protocol = 4 // Write a word then read a word
+	BlockData Protocol = 5 // Up to 32 bytes prefixed by their length
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case Quick:
+		return "Quick"
+	case Byte:
+		return "Byte"
+	case ByteData:
+		return "ByteData"
+	case WordData:
+		return "WordData"
+	case ProcCall:
+		return "ProcCall"
+	case BlockData:
+		return "BlockData"
+	default:
+		return fmt.Sprintf("Protocol(%d)", uint32(p))
+	}
+}
+
+// BlockMax is the maximum number of bytes in a BlockData transaction.
+const BlockMax = 32
+
+// Data is the data buffer of a SMBus transaction.
+//
+// The layout matches the kernel's union i2c_smbus_data: Byte and ByteData use
+// Data[0], WordData and ProcCall use Data[0:2] in little endian and BlockData
+// uses Data[0] as the length followed by the bytes.
+type Data [BlockMax + 2]byte
+
+// Native is optionally implemented by an i2c.Bus that can do SMBus
+// transactions natively.
+type Native interface {
+	// SMBus does a SMBus transaction with the device at addr.
+	//
+	// For ProcCall, data contains the word to write and receives the word
+	// read.
+	//
+	// It returns false without doing anything if this transaction is not
+	// supported natively, in which case it is emulated over Tx().
+	SMBus(addr uint16, read bool, cmd byte, p Protocol, pec bool, data *Data) (bool, error)
+}
+
+// Dev is a SMBus device on an I²C bus.
+//
+// It saves from repeatedly specifying the device address.
+type Dev struct {
+	Bus  i2c.Bus
+	Addr uint16
+	// PEC enables the Packet Error Code on every transaction. The device must
+	// support it.
+	PEC bool
+}
+
+func (d *Dev) String() string {
+	return fmt.Sprintf("%s(%d)", d.Bus, d.Addr)
+}
+
+// Quick does a quick write, which only sends the address with the write bit.
+func (d *Dev) Quick() error {
+	var data Data
+	return d.do(false, 0, Quick, &data)
+}
+
+// ReadByte reads a byte without sending a command.
+func (d *Dev) ReadByte() (byte, error) {
+	var data Data
+	err := d.do(true, 0, Byte, &data)
+	return data[0], err
+}
+
+// WriteByte writes a byte without a command.
+func (d *Dev) WriteByte(b byte) error {
+	var data Data
+	return d.do(false, b, Byte, &data)
+}
+
+// ReadByteData reads a byte after sending the command cmd, which is usually a
+// register address.
+func (d *Dev) ReadByteData(cmd byte) (byte, error) {
+	var data Data
+	err := d.do(true, cmd, ByteData, &data)
+	return data[0], err
+}
+
+// WriteByteData writes a byte after the command cmd.
+func (d *Dev) WriteByteData(cmd, b byte) error {
+	data := Data{b}
+	return d.do(false, cmd, ByteData, &data)
+}
+
+// ReadWordData reads a little endian word after sending the command cmd.
+func (d *Dev) ReadWordData(cmd byte) (uint16, error) {
+	var data Data
+	err := d.do(true, cmd, WordData, &data)
+	return binary.LittleEndian.Uint16(data[:]), err
+}
+
+// WriteWordData writes a little endian word after the command cmd.
+func (d *Dev) WriteWordData(cmd byte, w uint16) error {
+	var data Data
+	binary.LittleEndian.PutUint16(data[:], w)
+	return d.do(false, cmd, WordData, &data)
+}
+
+// ProcessCall writes a word after the command cmd and reads back a word in
+// the same transaction.
+func (d *Dev) ProcessCall(cmd byte, w uint16) (uint16, error) {
+	var data Data
+	binary.LittleEndian.PutUint16(data[:], w)
+	err := d.do(false, cmd, ProcCall, &data)
+	return binary.LittleEndian.Uint16(data[:]), err
+}
+
+// ReadBlockData reads up to BlockMax bytes after the command cmd. The device
+// specifies the number of bytes returned.
+func (d *Dev) ReadBlockData(cmd byte) ([]byte, error) {
+	var data Data
+	if err := d.do(true, cmd, BlockData, &data); err != nil {
+		return nil, err
+	}
+	if data[0] > BlockMax {
+		return nil, fmt.Errorf("smbus: invalid block length %d", data[0])
+	}
+	return append([]byte{}, data[1:1+data[0]]...), nil
+}
+
+// WriteBlockData writes up to BlockMax bytes prefixed by their length after
+// the command cmd.
+func (d *Dev) WriteBlockData(cmd byte, b []byte) error {
+	if len(b) > BlockMax {
+		return fmt.Errorf("smbus: block of %d bytes is larger than %d bytes", len(b), BlockMax)
+	}
+	var data Data
+	data[0] = byte(len(b))
+	copy(data[1:], b)
+	return d.do(false, cmd, BlockData, &data)
+}
+
+//
+
+var errPEC = errors.New("smbus: PEC mismatch")
+
+// do does a transaction natively if possible, otherwise it emulates it.
+func (d *Dev) do(read bool, cmd byte, p Protocol, data *Data) error {
+	if n, ok := d.Bus.(Native); ok {
+		if ok, err := n.SMBus(d.Addr, read, cmd, p, d.PEC, data); ok {
+			return err
+		}
+	}
+	return d.emulate(read, cmd, p, data)
+}
+
+// emulate does a transaction over Tx().
+//
+// A block read is emulated by reading BlockMax bytes after the length; the
+// device must tolerate being read past the end of the block.
+func (d *Dev) emulate(read bool, cmd byte, p Protocol, data *Data) error {
+	if p == Quick {
+		if read {
+			return errors.New("smbus: quick read is not supported over I²C")
+		}
+		return d.Bus.Tx(d.Addr, nil, nil)
+	}
+	wa := byte(d.Addr << 1)
+	ra := wa | 1
+	// w is the bytes written, r the buffer to read into. Both exclude the PEC.
+	var w []byte
+	var r []byte
+	switch p {
+	case Byte:
+		if read {
+			r = data[:1]
+		} else {
+			w = []byte{cmd}
+		}
+	case ByteData:
+		w = []byte{cmd}
+		if read {
+			r = data[:1]
+		} else {
+			w = append(w, data[0])
+		}
+	case WordData:
+		w = []byte{cmd}
+		if read {
+			r = data[:2]
+		} else {
+			w = append(w, data[0], data[1])
+		}
+	case ProcCall:
+		w = []byte{cmd, data[0], data[1]}
+		r = data[:2]
+	case BlockData:
+		w = []byte{cmd}
+		if read {
+			r = data[:1+BlockMax]
+		} else {
+			w = append(w, data[:1+data[0]]...)
+		}
+	default:
+		return fmt.Errorf("smbus: invalid protocol %s", p)
+	}
+	if !d.PEC {
+		return d.Bus.Tx(d.Addr, w, r)
+	}
+
+	// The PEC covers every byte on the wire, including the address bytes.
+	pec := []byte{wa}
+	pec = append(pec, w...)
+	if len(r) == 0 {
+		return d.Bus.Tx(d.Addr, append(w, CalcPEC(pec)), nil)
+	}
+	if len(w) == 0 {
+		pec = pec[:0]
+	}
+	pec = append(pec, ra)
+	// Read one more byte for the PEC.
+	buf := make([]byte, len(r)+1)
+	if err := d.Bus.Tx(d.Addr, w, buf); err != nil {
+		return err
+	}
+	n := len(r)
+	if p == BlockData {
+		if buf[0] > BlockMax {
+			return fmt.Errorf("smbus: invalid block length %d", buf[0])
+		}
+		n = 1 + int(buf[0])
+	}
+	if CalcPEC(append(pec, buf[:n]...)) != buf[n] {
+		return errPEC
+	}
+	copy(r, buf[:n])
+	return nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"testing"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+)
+
+func Example() {
+	//b, err := i2creg.Open("")
+	//defer b.Close()
+	var b i2c.Bus
+
+	// Read the voltage of a smart battery.
+	d := Dev{Bus: b, Addr: 0x0B, PEC: true}
+	mV, err := d.ReadWordData(0x09)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d mV\n", mV)
+}
+
+func TestDev_emulated(t *testing.T) {
+	p := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x0B, Write: nil, Read: nil},
+			{Addr: 0x0B, Write: nil, Read: []byte{0x12}},
+			{Addr: 0x0B, Write: []byte{0x34}, Read: nil},
+			{Addr: 0x0B, Write: []byte{0x01}, Read: []byte{0x56}},
+			{Addr: 0x0B, Write: []byte{0x02, 0x78}, Read: nil},
+			{Addr: 0x0B, Write: []byte{0x09}, Read: []byte{0x34, 0x12}},
+			{Addr: 0x0B, Write: []byte{0x0A, 0x78, 0x56}, Read: nil},
+			{Addr: 0x0B, Write: []byte{0x0B, 0x01, 0x02}, Read: []byte{0x03, 0x04}},
+			{Addr: 0x0B, Write: []byte{0x0C, 0x02, 'a', 'b'}, Read: nil},
+			{Addr: 0x0B, Write: []byte{0x0D}, Read: append([]byte{0x03, 'x', 'y', 'z'}, make([]byte, 29)...)},
+		},
+	}
+	d := Dev{Bus: &p, Addr: 0x0B}
+	if s := d.String(); s != "playback(11)" {
+		t.Fatal(s)
+	}
+	if err := d.Quick(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := d.ReadByte(); err != nil || b != 0x12 {
+		t.Fatal(b, err)
+	}
+	if err := d.WriteByte(0x34); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := d.ReadByteData(0x01); err != nil || b != 0x56 {
+		t.Fatal(b, err)
+	}
+	if err := d.WriteByteData(0x02, 0x78); err != nil {
+		t.Fatal(err)
+	}
+	if w, err := d.ReadWordData(0x09); err != nil || w != 0x1234 {
+		t.Fatal(w, err)
+	}
+	if err := d.WriteWordData(0x0A, 0x5678); err != nil {
+		t.Fatal(err)
+	}
+	if w, err := d.ProcessCall(0x0B, 0x0201); err != nil || w != 0x0403 {
+		t.Fatal(w, err)
+	}
+	if err := d.WriteBlockData(0x0C, []byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := d.ReadBlockData(0x0D); err != nil || string(b) != "xyz" {
+		t.Fatal(b, err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDev_emulatedPEC(t *testing.T) {
+	// Address 0x0B: write 0x16, read 0x17.
+	p := i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: 0x0B, Write: []byte{0x09}, Read: []byte{0x34, 0x12, CalcPEC([]byte{0x16, 0x09, 0x17, 0x34, 0x12})}},
+			{Addr: 0x0B, Write: []byte{0x0A, 0x78, 0x56, CalcPEC([]byte{0x16, 0x0A, 0x78, 0x56})}, Read: nil},
+			{Addr: 0x0B, Write: nil, Read: []byte{0x12, CalcPEC([]byte{0x17, 0x12})}},
+			{Addr: 0x0B, Write: []byte{0x0D}, Read: append([]byte{0x02, 'x', 'y', CalcPEC([]byte{0x16, 0x0D, 0x17, 0x02, 'x', 'y'})}, make([]byte, 30)...)},
+			{Addr: 0x0B, Write: []byte{0x09}, Read: []byte{0x34, 0x12, 0}},
+		},
+	}
+	d := Dev{Bus: &p, Addr: 0x0B, PEC: true}
+	if w, err := d.ReadWordData(0x09); err != nil || w != 0x1234 {
+		t.Fatal(w, err)
+	}
+	if err := d.WriteWordData(0x0A, 0x5678); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := d.ReadByte(); err != nil || b != 0x12 {
+		t.Fatal(b, err)
+	}
+	if b, err := d.ReadBlockData(0x0D); err != nil || string(b) != "xy" {
+		t.Fatal(b, err)
+	}
+	if _, err := d.ReadWordData(0x09); err != errPEC {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDev_errors(t *testing.T) {
+	d := Dev{Bus: &i2ctest.Playback{}, Addr: 0x0B}
+	if err := d.WriteBlockData(0, make([]byte, 33)); err == nil {
+		t.Fatal("block too large")
+	}
+	var data Data
+	if err := d.do(true, 0, Quick, &data); err == nil {
+		t.Fatal("quick read is not supported")
+	}
+	if err := d.do(true, 0, Protocol(10), &data); err == nil {
+		t.Fatal("invalid protocol")
+	}
+	p := i2ctest.Playback{
+		Ops: []i2ctest.IO{{Addr: 0x0B, Write: []byte{0x0D}, Read: append([]byte{33}, make([]byte, 32)...)}},
+	}
+	d.Bus = &p
+	if _, err := d.ReadBlockData(0x0D); err == nil {
+		t.Fatal("invalid block length")
+	}
+}
+
+func TestDev_native(t *testing.T) {
+	n := &nativeBus{}
+	d := Dev{Bus: n, Addr: 0x0B, PEC: true}
+	n.data[0] = 3
+	copy(n.data[1:], "abc")
+	if b, err := d.ReadBlockData(0x0D); err != nil || string(b) != "abc" {
+		t.Fatal(b, err)
+	}
+	if n.addr != 0x0B || !n.read || n.cmd != 0x0D || n.p != BlockData || !n.pec {
+		t.Fatal(n)
+	}
+	n.data = Data{0x03, 0x04}
+	if w, err := d.ProcessCall(0x0B, 0x0201); err != nil || w != 0x0403 {
+		t.Fatal(w, err)
+	}
+	if n.read || n.p != ProcCall || !bytes.Equal(n.written[:2], []byte{0x01, 0x02}) {
+		t.Fatal(n)
+	}
+	n.err = errors.New("foo")
+	if err := d.Quick(); err != n.err {
+		t.Fatal(err)
+	}
+	// Falls back to Tx() when not supported natively.
+	n.unsupported = true
+	if err := d.WriteByteData(0x01, 0x02); err != nil {
+		t.Fatal(err)
+	}
+	if n.tx != 1 {
+		t.Fatal(n.tx)
+	}
+}
+
+func TestProtocol_String(t *testing.T) {
+	if s := BlockData.String(); s != "BlockData" {
+		t.Fatal(s)
+	}
+	if s := Protocol(10).String(); s != "Protocol(10)" {
+		t.Fatal(s)
+	}
+}
+
+//
+
+// nativeBus implements Native; it records the transaction and returns data.
+type nativeBus struct {
+	unsupported bool
+	err         error
+	addr        uint16
+	read        bool
+	cmd         byte
+	p           Protocol
+	pec         bool
+	written     Data
+	data        Data
+	tx          int
+}
+
+func (n *nativeBus) Tx(addr uint16, w, r []byte) error {
+	n.tx++
+	return nil
+}
+
+func (n *nativeBus) Speed(hz int64) error {
+	return nil
+}
+
+func (n *nativeBus) SMBus(addr uint16, read bool, cmd byte, p Protocol, pec bool, data *Data) (bool, error) {
+	if n.unsupported {
+		return false, nil
+	}
+	n.addr = addr
+	n.read = read
+	n.cmd = cmd
+	n.p = p
+	n.pec = pec
+	n.written = *data
+	*data = n.data
+	return true, n.err
+}
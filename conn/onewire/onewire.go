@@ -16,6 +16,7 @@
 package onewire
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -50,6 +51,14 @@ type Bus interface {
 	Search(alarmOnly bool) ([]Address, error)
 }
 
+// BusContext is optionally implemented by a Bus whose transactions can be
+// cancelled.
+type BusContext interface {
+	// TxContext performs a bus transaction like Tx() but gives up when ctx is
+	// done, in which case ctx.Err() is returned.
+	TxContext(ctx context.Context, w, r []byte, power Pullup) error
+}
+
 // Address represents a 1-wire device address in little-endian format. This means
 // that the family code ends up in the lower byte, the CRC in the top byte,
 // and the variable address part in the middle 6 bytes. E.g. a DS18B20 device,
@@ -167,6 +176,25 @@ func (d *Dev) Tx(w, r []byte) error {
 	return d.Bus.Tx(ww, r, WeakPullup)
 }
 
+// TxContext is like Tx() but the transaction can be cancelled via ctx;
+// implements conn.ConnContext.
+//
+// It's a wrapper for Dev.Bus.TxContext() if the bus implements BusContext.
+// Otherwise ctx is checked before calling Dev.Bus.Tx().
+func (d *Dev) TxContext(ctx context.Context, w, r []byte) error {
+	ww := make([]byte, 9, len(w)+9)
+	ww[0] = 0x55 // Match ROM
+	binary.LittleEndian.PutUint64(ww[1:], uint64(d.Addr))
+	ww = append(ww, w...)
+	if b, ok := d.Bus.(BusContext); ok {
+		return b.TxContext(ctx, ww, r, WeakPullup)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.Bus.Tx(ww, r, WeakPullup)
+}
+
 // Duplex always return conn.Half for 1-wire.
 func (d *Dev) Duplex() conn.Duplex {
 	return conn.Half
@@ -190,6 +218,7 @@ func (d *Dev) TxPower(w, r []byte) error {
 
 // Ensure that the appropriate interfaces are implemented.
 var _ conn.Conn = &Dev{}
+var _ conn.ConnContext = &Dev{}
 var _ NoDevicesError = noDevicesError("")
 var _ ShortedBusError = shortedBusError("")
 var _ BusError = busError("")
@@ -6,6 +6,7 @@ package onewire
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -99,6 +100,27 @@ func TestDevTx(t *testing.T) {
 	}
 }
 
+func TestDevTxContext(t *testing.T) {
+	b := &fakeBus{r: []byte{1}}
+	d := Dev{b, 12}
+	r := make([]byte, 1)
+	if err := d.TxContext(context.Background(), []byte{3}, r); err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{85, 12, 0, 0, 0, 0, 0, 0, 0, 3}
+	if !bytes.Equal(b.w, expected) || r[0] != 1 || b.power != WeakPullup {
+		t.Fatal(b.w, r)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.TxContext(ctx, []byte{4}, nil); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.w, expected) {
+		t.Fatal(b.w)
+	}
+}
+
 func TestDevTxPower(t *testing.T) {
 	b := nopBus("hi")
 	d := Dev{Bus: &b, Addr: 12}
@@ -170,11 +170,7 @@ func fakeBuser() (onewire.BusCloser, error) {
 }
 
 func reset() {
-	mu.Lock()
-	defer mu.Unlock()
-	byName = map[string]*Ref{}
-	byNumber = map[int]*Ref{}
-	byAlias = map[string]*Ref{}
+	Clear()
 }
 
 type fakeBus struct {
@@ -0,0 +1,54 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package onewiretest
+
+import (
+	"periph.io/x/periph/conn/conntest"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/onewire"
+)
+
+// Fault implements onewire.Bus and injects faults in the transactions done on
+// Bus.
+//
+// See conntest.Faults for the faults that can be injected. Use
+// conntest.BusError or conntest.ShortedBusError as Err to simulate 1-wire
+// errors. Search is not affected.
+type Fault struct {
+	conntest.Faults
+	Bus onewire.Bus
+}
+
+func (f *Fault) String() string {
+	return "fault"
+}
+
+// Tx implements onewire.Bus.
+func (f *Fault) Tx(w, r []byte, pull onewire.Pullup) error {
+	if err := f.Inject(); err != nil {
+		return err
+	}
+	if err := f.Bus.Tx(w, r, pull); err != nil {
+		return err
+	}
+	f.Corrupt(r)
+	return nil
+}
+
+// Search implements onewire.Bus.
+func (f *Fault) Search(alarmOnly bool) ([]onewire.Address, error) {
+	return f.Bus.Search(alarmOnly)
+}
+
+// Q implements onewire.Pins.
+func (f *Fault) Q() gpio.PinIO {
+	if p, ok := f.Bus.(onewire.Pins); ok {
+		return p.Q()
+	}
+	return gpio.INVALID
+}
+
+var _ onewire.Bus = &Fault{}
+var _ onewire.Pins = &Fault{}
@@ -0,0 +1,50 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package onewiretest
+
+import (
+	"testing"
+
+	"periph.io/x/periph/conn/conntest"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/onewire"
+)
+
+func TestFault(t *testing.T) {
+	p := &Playback{
+		Ops:  []IO{{Write: []byte{0xCC, 0x44}, Pull: onewire.StrongPullup}},
+		QPin: &gpiotest.Pin{N: "Q"},
+	}
+	f := &Fault{Faults: conntest.Faults{Every: 1, Err: conntest.ShortedBusError("onewire: bus is shorted")}, Bus: p}
+	if s := f.String(); s != "fault" {
+		t.Fatal(s)
+	}
+	if q := f.Q(); q.Name() != "Q" {
+		t.Fatal(q)
+	}
+	err := f.Tx([]byte{0xCC, 0x44}, nil, onewire.StrongPullup)
+	if s, ok := err.(onewire.ShortedBusError); !ok || !s.IsShorted() {
+		t.Fatal(err)
+	}
+	if b, ok := err.(onewire.BusError); !ok || !b.BusError() {
+		t.Fatal(err)
+	}
+	f.Every = 0
+	if err := f.Tx([]byte{0xCC, 0x44}, nil, onewire.StrongPullup); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Search is not affected.
+	f = &Fault{Faults: conntest.Faults{Every: 1}, Bus: &Record{}}
+	if a, err := f.Search(false); len(a) != 0 || err != nil {
+		t.Fatal(a, err)
+	}
+	if q := f.Q(); q != gpio.INVALID {
+		t.Fatal(q)
+	}
+}
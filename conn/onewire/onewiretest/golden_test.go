@@ -0,0 +1,84 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package onewiretest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"periph.io/x/periph/conn/onewire"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	ops := []IO{
+		{Write: []byte{0xCC, 0x44}, Pull: onewire.StrongPullup},
+		{Write: []byte{0xCC, 0xBE}, Read: []byte{1, 2}},
+	}
+	b := bytes.Buffer{}
+	if err := Encode(&b, ops); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.String(); s != "w=cc44 pull=strong\nw=ccbe r=0102\n" {
+		t.Fatalf("%q", s)
+	}
+	got, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, ops) {
+		t.Fatalf("%#v", got)
+	}
+}
+
+func TestDecode_err(t *testing.T) {
+	data := []struct {
+		in  string
+		err string
+	}{
+		{"pull=weak", "onewiretest: line 1: missing field w"},
+		{"w=01 pull=none", "onewiretest: line 1: invalid field \"pull=none\": expected strong or weak"},
+		{"w=01 x=02", "onewiretest: line 1: unknown field \"x=02\""},
+	}
+	for i, line := range data {
+		if _, err := Decode(strings.NewReader(line.in)); err == nil || err.Error() != line.err {
+			t.Fatalf("#%d: %v", i, err)
+		}
+	}
+	var io IO
+	if err := io.UnmarshalText([]byte("w=01 pull=weak")); err != nil || io.Pull != onewire.WeakPullup {
+		t.Fatal(err)
+	}
+}
+
+func TestRecord_Save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "onewiretest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "golden.txt")
+	r := Record{}
+	if err := r.Tx([]byte{0xCC, 0x44}, nil, onewire.StrongPullup); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPlayback(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Tx([]byte{0xCC, 0x44}, nil, onewire.StrongPullup); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -38,6 +38,15 @@ type Pin interface {
 	Function() string
 }
 
+// PinFunc is optionally implemented by a Pin whose function can be changed
+// back after it was used as a GPIO, for example to hand it back to the I²C
+// controller.
+type PinFunc interface {
+	// SetFunction changes the pin function to f, which must be a value
+	// returned by Function() for this pin.
+	SetFunction(f string) error
+}
+
 //
 
 // BasicPin implements Pin as a non-functional pin.
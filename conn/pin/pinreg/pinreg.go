@@ -76,6 +76,17 @@ func Register(name string, allPins [][]pin.Pin) error {
 	return nil
 }
 
+// Clear unregisters all the headers.
+//
+// It is called by periph.Shutdown() so drivers can register them again on the
+// next periph.Init().
+func Clear() {
+	mu.Lock()
+	defer mu.Unlock()
+	allHeaders = map[string][][]pin.Pin{}
+	byPin = map[string]position{}
+}
+
 //
 
 type position struct {
@@ -73,10 +73,7 @@ func TestIsConnected(t *testing.T) {
 //
 
 func reset() {
-	mu.Lock()
-	defer mu.Unlock()
-	allHeaders = map[string][][]pin.Pin{}
-	byPin = map[string]position{}
+	Clear()
 }
 
 type pinAlias struct {
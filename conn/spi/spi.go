@@ -6,7 +6,10 @@
 package spi
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"time"
 
 	"periph.io/x/periph/conn"
 	"periph.io/x/periph/conn/gpio"
@@ -18,6 +21,8 @@ import (
 // CPOL means the clock polarity. Idle is High when set.
 //
 // CPHA is the clock phase, sample on trailing edge when set.
+//
+// HalfDuplex, LSBFirst and NoCS can be OR'ed to one of the clock and phase values.
 type Mode int
 
 // Valid SPI clock and phase.
@@ -28,6 +33,20 @@ const (
 	Mode3 Mode = 0x3 // CPOL=1, CPHA=1
 )
 
+// Additional flags.
+const (
+	// HalfDuplex specifies that MOSI and MISO share a single data wire, also
+	// known as 3-wire SPI. Duplex() then returns conn.Half and reading happens
+	// after writing in Tx().
+	HalfDuplex Mode = 0x4
+	// LSBFirst specifies that the least significant bit of each word is sent
+	// first. The default is the most significant bit first.
+	LSBFirst Mode = 0x8
+	// NoCS specifies that the SPI controller doesn't drive the CS line, for
+	// example because a GPIO pin is used as CS instead.
+	NoCS Mode = 0x10
+)
+
 // Conn defines the interface a concrete SPI driver must implement.
 //
 // It is expected to implement fmt.Stringer and optionally io.Writer and
@@ -41,9 +60,81 @@ type Conn interface {
 	// maximum rated speed by the device's spec. The lowest speed between the bus
 	// speed and the device speed is selected. Use 0 for maxHz if there is no
 	// known maximum value for this device.
+	//
+	// bits is the number of bits per word, usually 8. Words of 9 to 16 bits
+	// are stored in little endian on 2 bytes in the buffers passed to Tx() and
+	// words of 17 to 32 bits on 4 bytes, so the buffers' length must be a
+	// multiple of the word size.
 	DevParams(maxHz int64, mode Mode, bits int) error
 }
 
+// Packet is one segment of a transaction done with TxPackets().
+//
+// All the packets of a transaction are sent while CS is asserted, unless
+// CSChange is set.
+type Packet struct {
+	// W and R are the data to write and the buffer to read into. Either can be
+	// empty, for example for a command phase followed by a read phase. When
+	// both are specified, they must have the same length.
+	W, R []byte
+	// Hz overrides the speed for this packet when not 0.
+	Hz int64
+	// BitsPerWord overrides the number of bits per word for this packet when
+	// not 0. See Conn.DevParams() for how words are stored.
+	BitsPerWord int
+	// Delay is the time to wait after this packet, before the next one or
+	// before CS is deasserted.
+	Delay time.Duration
+	// CSChange deasserts CS after this packet and asserts it again before the
+	// next one. On the last packet, it instead keeps CS asserted after the
+	// transaction; this is a hint that the next transaction is for the same
+	// device.
+	CSChange bool
+}
+
+// ConnPackets is optionally implemented by a Conn that supports transactions
+// made of multiple packets.
+type ConnPackets interface {
+	// TxPackets does a transaction made of p as a single operation unit.
+	TxPackets(p []Packet) error
+}
+
+// TxPackets does a transaction made of p on c.
+//
+// It's a wrapper for ConnPackets.TxPackets() if the connection implements
+// ConnPackets. Otherwise only a single packet without overrides can be sent
+// via Conn.Tx().
+func TxPackets(c Conn, p []Packet) error {
+	if cp, ok := c.(ConnPackets); ok {
+		return cp.TxPackets(p)
+	}
+	if len(p) != 1 || p[0].Hz != 0 || p[0].BitsPerWord != 0 || p[0].Delay != 0 || p[0].CSChange {
+		return fmt.Errorf("spi: %v doesn't support this sequence of packets", c)
+	}
+	return c.Tx(p[0].W, p[0].R)
+}
+
+// ValidatePackets returns an error if a packet is invalid.
+//
+// It is meant to be used by the implementations of ConnPackets.
+func ValidatePackets(p []Packet) error {
+	if len(p) == 0 {
+		return errors.New("spi: no packet to send")
+	}
+	for i := range p {
+		if len(p[i].W) == 0 && len(p[i].R) == 0 {
+			return fmt.Errorf("spi: packet #%d is empty", i)
+		}
+		if len(p[i].W) != 0 && len(p[i].R) != 0 && len(p[i].W) != len(p[i].R) {
+			return fmt.Errorf("spi: packet #%d has buffers of different lengths %d and %d", i, len(p[i].W), len(p[i].R))
+		}
+		if p[i].Hz < 0 || p[i].BitsPerWord < 0 || p[i].Delay < 0 {
+			return fmt.Errorf("spi: packet #%d has a negative value", i)
+		}
+	}
+	return nil
+}
+
 // ConnCloser is a SPI bus that can be closed.
 //
 // This interface is meant to be handled by the application.
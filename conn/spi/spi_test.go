@@ -4,7 +4,14 @@
 
 package spi
 
-import "fmt"
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn"
+)
 
 func ExamplePins() {
 	//b, err := spireg.Open("")
@@ -19,3 +26,83 @@ func ExamplePins() {
 		fmt.Printf("  CS  : %s", p.CS())
 	}
 }
+
+func TestTxPackets(t *testing.T) {
+	c := &fakeConn{}
+	w := []byte{1}
+	if err := TxPackets(c, []Packet{{W: w}}); err != nil || !bytes.Equal(c.w, w) {
+		t.Fatal(err, c.w)
+	}
+	invalid := [][]Packet{
+		nil,
+		{{W: w}, {W: w}},
+		{{W: w, Hz: 1}},
+		{{W: w, BitsPerWord: 9}},
+		{{W: w, Delay: time.Microsecond}},
+		{{W: w, CSChange: true}},
+	}
+	for i, p := range invalid {
+		if err := TxPackets(c, p); err == nil {
+			t.Fatalf("#%d: expected error", i)
+		}
+	}
+	cp := &fakeConnPackets{}
+	p := []Packet{{W: w}, {R: make([]byte, 2), CSChange: true}}
+	if err := TxPackets(cp, p); err != nil || len(cp.p) != 2 {
+		t.Fatal(err, cp.p)
+	}
+}
+
+func TestValidatePackets(t *testing.T) {
+	w := []byte{1, 2}
+	if err := ValidatePackets([]Packet{{W: w}, {R: w}, {W: w, R: make([]byte, 2)}}); err != nil {
+		t.Fatal(err)
+	}
+	invalid := [][]Packet{
+		nil,
+		{{}},
+		{{W: w, R: make([]byte, 3)}},
+		{{W: w, Hz: -1}},
+		{{W: w, BitsPerWord: -1}},
+		{{W: w, Delay: -1}},
+	}
+	for i, p := range invalid {
+		if err := ValidatePackets(p); err == nil {
+			t.Fatalf("#%d: expected error", i)
+		}
+	}
+}
+
+//
+
+type fakeConn struct {
+	w, r []byte
+}
+
+func (f *fakeConn) String() string {
+	return "fake"
+}
+
+func (f *fakeConn) Tx(w, r []byte) error {
+	f.w = append(f.w, w...)
+	copy(r, f.r)
+	return nil
+}
+
+func (f *fakeConn) Duplex() conn.Duplex {
+	return conn.Full
+}
+
+func (f *fakeConn) DevParams(maxHz int64, mode Mode, bits int) error {
+	return nil
+}
+
+type fakeConnPackets struct {
+	fakeConn
+	p []Packet
+}
+
+func (f *fakeConnPackets) TxPackets(p []Packet) error {
+	f.p = p
+	return nil
+}
@@ -0,0 +1,338 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package spics shares a SPI bus between multiple devices by using GPIO pins
+// as chip select.
+//
+// This is useful when more devices are connected than the SPI controller has
+// CS lines, for example on a Raspberry Pi. The SPI bus is used in spi.NoCS
+// mode so the controller doesn't drive its own CS line.
+package spics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/spi"
+	"periph.io/x/periph/conn/spi/spireg"
+)
+
+// Bus is a SPI bus shared by devices that each use a GPIO pin as CS.
+//
+// The bus is configured with the first call to DevParams() of one of its
+// devices. All the devices must use the same mode.
+//
+// The speed of a device is set with Speed() on the underlying bus before its
+// transactions; a device without a speed uses the last one set. A device
+// using a number of bits per word different from the first device configured
+// requires the underlying bus to implement spi.ConnPackets.
+//
+// The resulting object is safe for concurrent use.
+type Bus struct {
+	// Immutable.
+	name string
+	c    spi.ConnCloser
+
+	mu         sync.Mutex
+	configured bool
+	mode       spi.Mode
+	bits       int
+	hz         int64
+}
+
+// New returns a Bus sharing c.
+//
+// DevParams() must not have been called on c; it is called with spi.NoCS
+// added to the mode on the first call to DevParams() of one of the devices.
+//
+// name is used as the prefix of the devices' name. For example use "SPI0" to
+// have a device using GPIO22 named "SPI0.gpio22".
+func New(name string, c spi.ConnCloser) *Bus {
+	return &Bus{name: name, c: c}
+}
+
+func (b *Bus) String() string {
+	return b.name
+}
+
+// Close closes the underlying SPI bus.
+func (b *Bus) Close() error {
+	return b.c.Close()
+}
+
+// Dev returns a device on the bus using cs as its chip select.
+//
+// cs is set to High right away, as CS is active low.
+func (b *Bus) Dev(cs gpio.PinOut) (*Dev, error) {
+	if err := cs.Out(gpio.High); err != nil {
+		return nil, fmt.Errorf("spics: %s: %v", cs, err)
+	}
+	return &Dev{b: b, cs: cs}, nil
+}
+
+// Register registers in spireg a device on the bus using cs as its chip
+// select.
+//
+// The device is named after the bus and the lower case name of the pin, for
+// example "SPI0.gpio22". Each call to spireg.Open() returns a new Dev.
+func (b *Bus) Register(cs gpio.PinOut) error {
+	return spireg.Register(b.devName(cs), nil, -1, func() (spi.ConnCloser, error) {
+		return b.Dev(cs)
+	})
+}
+
+// Dev is a device on a Bus.
+//
+// It implements spi.ConnCloser.
+type Dev struct {
+	// Immutable.
+	b  *Bus
+	cs gpio.PinOut
+
+	mu       sync.Mutex
+	maxHzBus int64
+	maxHzDev int64
+	bits     int
+}
+
+func (d *Dev) String() string {
+	return d.b.devName(d.cs)
+}
+
+// Close implements spi.ConnCloser.
+//
+// It is a no-op as the bus is shared. Use Bus.Close() to close the bus.
+func (d *Dev) Close() error {
+	return nil
+}
+
+// Speed implements spi.ConnCloser.
+//
+// It only affects this device.
+func (d *Dev) Speed(maxHz int64) error {
+	if maxHz < 1 {
+		return fmt.Errorf("spics: invalid speed %d", maxHz)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxHzBus = maxHz
+	return nil
+}
+
+// DevParams implements spi.Conn.
+//
+// mode must be the same for all the devices on the bus.
+func (d *Dev) DevParams(maxHz int64, mode spi.Mode, bits int) error {
+	if maxHz < 0 {
+		return fmt.Errorf("spics: invalid speed %d", maxHz)
+	}
+	if bits < 1 {
+		return fmt.Errorf("spics: invalid bits %d", bits)
+	}
+	if err := d.b.devParams(mode|spi.NoCS, bits); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxHzDev = maxHz
+	d.bits = bits
+	return nil
+}
+
+// Tx implements spi.Conn.
+//
+// CS is asserted during the transaction. Transactions are serialized with the
+// ones of the other devices on the bus.
+func (d *Dev) Tx(w, r []byte) error {
+	hz, bits, err := d.params()
+	if err != nil {
+		return err
+	}
+	d.b.mu.Lock()
+	defer d.b.mu.Unlock()
+	if err := d.b.speed(hz); err != nil {
+		return err
+	}
+	if bits == d.b.bits {
+		return d.tx(func() error { return d.b.c.Tx(w, r) })
+	}
+	p := []spi.Packet{{W: w, R: r, BitsPerWord: bits}}
+	if d.b.c.Duplex() == conn.Half && len(w) != 0 && len(r) != 0 {
+		// Write then read, without deasserting CS in between.
+		p = []spi.Packet{{W: w, BitsPerWord: bits}, {R: r, BitsPerWord: bits}}
+	}
+	return d.tx(func() error { return spi.TxPackets(d.b.c, p) })
+}
+
+// TxPackets implements spi.ConnPackets.
+//
+// The number of bits per word of the device is used for the packets that do
+// not override it. CSChange is not supported.
+func (d *Dev) TxPackets(p []spi.Packet) error {
+	hz, bits, err := d.params()
+	if err != nil {
+		return err
+	}
+	pkts := make([]spi.Packet, len(p))
+	for i := range p {
+		if p[i].CSChange {
+			return fmt.Errorf("spics: packet #%d: CSChange is not supported", i)
+		}
+		pkts[i] = p[i]
+		// Only override the number of bits per word when needed, so that a bus
+		// that doesn't implement spi.ConnPackets can still be used.
+		if pkts[i].BitsPerWord == 0 && bits != d.b.bits {
+			pkts[i].BitsPerWord = bits
+		}
+	}
+	d.b.mu.Lock()
+	defer d.b.mu.Unlock()
+	if err := d.b.speed(hz); err != nil {
+		return err
+	}
+	return d.tx(func() error { return spi.TxPackets(d.b.c, pkts) })
+}
+
+// Read implements io.Reader.
+func (d *Dev) Read(b []byte) (int, error) {
+	if err := d.Tx(nil, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Write implements io.Writer.
+func (d *Dev) Write(b []byte) (int, error) {
+	if err := d.Tx(b, nil); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// MaxTxSize implements conn.Limits.
+//
+// It returns the limit of the underlying bus, or 0 if it doesn't report one.
+func (d *Dev) MaxTxSize() int {
+	if l, ok := d.b.c.(conn.Limits); ok {
+		return l.MaxTxSize()
+	}
+	return 0
+}
+
+// Duplex implements spi.Conn.
+func (d *Dev) Duplex() conn.Duplex {
+	return d.b.c.Duplex()
+}
+
+// CLK implements spi.Pins.
+func (d *Dev) CLK() gpio.PinOut {
+	if p, ok := d.b.c.(spi.Pins); ok {
+		return p.CLK()
+	}
+	return gpio.INVALID
+}
+
+// MOSI implements spi.Pins.
+func (d *Dev) MOSI() gpio.PinOut {
+	if p, ok := d.b.c.(spi.Pins); ok {
+		return p.MOSI()
+	}
+	return gpio.INVALID
+}
+
+// MISO implements spi.Pins.
+func (d *Dev) MISO() gpio.PinIn {
+	if p, ok := d.b.c.(spi.Pins); ok {
+		return p.MISO()
+	}
+	return gpio.INVALID
+}
+
+// CS implements spi.Pins.
+//
+// It returns the GPIO pin used as chip select.
+func (d *Dev) CS() gpio.PinOut {
+	return d.cs
+}
+
+//
+
+// params returns the speed and the number of bits per word of the device.
+func (d *Dev) params() (int64, int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.bits == 0 {
+		return 0, 0, errors.New("spics: DevParams wasn't called")
+	}
+	hz := d.maxHzDev
+	if hz == 0 || (d.maxHzBus != 0 && d.maxHzBus < hz) {
+		hz = d.maxHzBus
+	}
+	return hz, d.bits, nil
+}
+
+// tx runs f with CS asserted.
+//
+// It must be called with the bus lock held.
+func (d *Dev) tx(f func() error) error {
+	if err := d.cs.Out(gpio.Low); err != nil {
+		return fmt.Errorf("spics: %s: %v", d.cs, err)
+	}
+	err := f()
+	if err2 := d.cs.Out(gpio.High); err == nil && err2 != nil {
+		err = fmt.Errorf("spics: %s: %v", d.cs, err2)
+	}
+	return err
+}
+
+func (b *Bus) devName(cs gpio.PinOut) string {
+	return b.name + "." + strings.ToLower(cs.Name())
+}
+
+// devParams configures the underlying bus on the first call and verifies the
+// mode matches on the following ones.
+func (b *Bus) devParams(mode spi.Mode, bits int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.configured {
+		if mode != b.mode {
+			return fmt.Errorf("spics: %s: mode 0x%x differs from mode 0x%x used by the other devices", b, int(mode), int(b.mode))
+		}
+		return nil
+	}
+	if err := b.c.DevParams(0, mode, bits); err != nil {
+		return err
+	}
+	b.configured = true
+	b.mode = mode
+	b.bits = bits
+	return nil
+}
+
+// speed sets the speed of the underlying bus to hz, unless it is 0 or already
+// set.
+//
+// It must be called with mu held.
+func (b *Bus) speed(hz int64) error {
+	if hz == 0 || hz == b.hz {
+		return nil
+	}
+	if err := b.c.Speed(hz); err != nil {
+		return err
+	}
+	b.hz = hz
+	return nil
+}
+
+var _ spi.ConnCloser = &Dev{}
+var _ spi.ConnPackets = &Dev{}
+var _ spi.Pins = &Dev{}
+var _ conn.Limits = &Dev{}
+var _ io.Reader = &Dev{}
+var _ io.Writer = &Dev{}
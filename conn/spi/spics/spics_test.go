@@ -0,0 +1,275 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package spics
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/spi"
+	"periph.io/x/periph/conn/spi/spireg"
+)
+
+func TestDev(t *testing.T) {
+	f := &fakeBus{}
+	b := New("SPI0", f)
+	cs1 := &gpiotest.Pin{N: "GPIO22", L: gpio.Low}
+	cs2 := &gpiotest.Pin{N: "GPIO23", L: gpio.Low}
+	d1, err := b.Dev(cs1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := b.Dev(cs2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs1.L != gpio.High || cs2.L != gpio.High {
+		t.Fatal("CS must be deasserted")
+	}
+	if s := d1.String(); s != "SPI0.gpio22" {
+		t.Fatal(s)
+	}
+	if d1.Tx([]byte{1}, nil) == nil {
+		t.Fatal("DevParams wasn't called")
+	}
+	if err := d1.DevParams(1000, spi.Mode3, 8); err != nil {
+		t.Fatal(err)
+	}
+	if f.mode != spi.Mode3|spi.NoCS || f.bits != 8 {
+		t.Fatal(f.mode, f.bits)
+	}
+	if d2.DevParams(0, spi.Mode0, 9) == nil {
+		t.Fatal("mode differs")
+	}
+	if err := d2.DevParams(0, spi.Mode3, 9); err != nil {
+		t.Fatal(err)
+	}
+	if err := d2.Speed(500); err != nil {
+		t.Fatal(err)
+	}
+
+	f.cs = cs1
+	r := make([]byte, 2)
+	if err := d1.Tx([]byte{1, 2}, r); err != nil {
+		t.Fatal(err)
+	}
+	// The number of bits per word matches the bus, so Tx() is used directly.
+	if f.hz != 1000 || f.p != nil || !bytes.Equal(f.w, []byte{1, 2}) || !bytes.Equal(r, []byte{1, 2}) {
+		t.Fatalf("%d %#v %v %v", f.hz, f.p, f.w, r)
+	}
+	if cs1.L != gpio.High {
+		t.Fatal("CS must be deasserted")
+	}
+	f.cs = cs2
+	if n, err := d2.Write([]byte{1, 2}); n != 2 || err != nil {
+		t.Fatal(n, err)
+	}
+	if f.hz != 500 || len(f.p) != 1 || f.p[0].Hz != 0 || f.p[0].BitsPerWord != 9 {
+		t.Fatalf("%d %#v", f.hz, f.p)
+	}
+	if n, err := d2.Read(r); n != 2 || err != nil {
+		t.Fatal(n, err)
+	}
+	if d2.TxPackets([]spi.Packet{{W: []byte{1}, CSChange: true}}) == nil {
+		t.Fatal("CSChange is not supported")
+	}
+	f.err = errors.New("oops")
+	if d2.Tx([]byte{1}, nil) == nil {
+		t.Fatal("expected error")
+	}
+	if cs2.L != gpio.High {
+		t.Fatal("CS must be deasserted")
+	}
+
+	if d := d1.Duplex(); d != conn.Full {
+		t.Fatal(d)
+	}
+	if n := d1.MaxTxSize(); n != 4096 {
+		t.Fatal(n)
+	}
+	if d1.CS() != cs1 || d1.CLK() != gpio.INVALID || d1.MOSI() != gpio.INVALID || d1.MISO() != gpio.INVALID {
+		t.Fatal("unexpected pins")
+	}
+	if d1.Speed(0) == nil || d1.DevParams(-1, spi.Mode3, 8) == nil || d1.DevParams(0, spi.Mode3, 0) == nil {
+		t.Fatal("invalid arguments")
+	}
+	if err := d1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDev_halfDuplex(t *testing.T) {
+	f := &fakeBus{fakeConn: fakeConn{duplex: conn.Half}}
+	b := New("SPI0", f)
+	d1, err := b.Dev(&gpiotest.Pin{N: "GPIO22"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := b.Dev(&gpiotest.Pin{N: "GPIO23"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d1.DevParams(0, spi.HalfDuplex, 8); err != nil {
+		t.Fatal(err)
+	}
+	if err := d2.DevParams(0, spi.HalfDuplex, 16); err != nil {
+		t.Fatal(err)
+	}
+	// The underlying bus handles the half-duplex transaction itself.
+	r := make([]byte, 2)
+	if err := d1.Tx([]byte{1}, r); err != nil {
+		t.Fatal(err)
+	}
+	if f.p != nil || !bytes.Equal(f.w, []byte{1}) {
+		t.Fatalf("%#v %v", f.p, f.w)
+	}
+	// Overriding the number of bits per word requires splitting the write and
+	// the read.
+	if err := d2.Tx([]byte{1, 2}, r); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.p) != 2 || len(f.p[0].W) != 2 || len(f.p[0].R) != 0 || len(f.p[1].W) != 0 || len(f.p[1].R) != 2 || f.p[0].BitsPerWord != 16 || f.p[1].BitsPerWord != 16 {
+		t.Fatalf("%#v", f.p)
+	}
+	if d := d2.Duplex(); d != conn.Half {
+		t.Fatal(d)
+	}
+}
+
+func TestDev_noPackets(t *testing.T) {
+	f := &fakeConn{}
+	b := New("SPI0", f)
+	d1, err := b.Dev(&gpiotest.Pin{N: "GPIO22"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := b.Dev(&gpiotest.Pin{N: "GPIO23"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d1.DevParams(1000, spi.Mode0, 8); err != nil {
+		t.Fatal(err)
+	}
+	if err := d2.DevParams(0, spi.Mode0, 9); err != nil {
+		t.Fatal(err)
+	}
+	if err := d1.Tx([]byte{1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if f.hz != 1000 || !bytes.Equal(f.w, []byte{1}) {
+		t.Fatal(f.hz, f.w)
+	}
+	if err := d1.TxPackets([]spi.Packet{{W: []byte{2}}}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.w, []byte{2}) {
+		t.Fatal(f.w)
+	}
+	if d2.Tx([]byte{1}, nil) == nil {
+		t.Fatal("the bus doesn't support overriding the number of bits per word")
+	}
+	if n := d1.MaxTxSize(); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestBus_Dev_fail(t *testing.T) {
+	b := New("SPI0", &fakeBus{})
+	if _, err := b.Dev(gpio.INVALID); err == nil {
+		t.Fatal("invalid pin")
+	}
+}
+
+func TestBus_Register(t *testing.T) {
+	b := New("SPI0", &fakeBus{})
+	cs := &gpiotest.Pin{N: "GPIO22"}
+	if err := b.Register(cs); err != nil {
+		t.Fatal(err)
+	}
+	defer spireg.Unregister("SPI0.gpio22")
+	c, err := spireg.Open("SPI0.gpio22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.(*Dev).CS() != cs {
+		t.Fatal("unexpected CS")
+	}
+}
+
+//
+
+// fakeConn implements spi.ConnCloser. It echoes the data written.
+type fakeConn struct {
+	mode   spi.Mode
+	bits   int
+	hz     int64
+	duplex conn.Duplex
+	cs     *gpiotest.Pin
+	w      []byte
+	err    error
+}
+
+func (f *fakeConn) String() string {
+	return "fake"
+}
+
+func (f *fakeConn) Close() error {
+	return nil
+}
+
+func (f *fakeConn) Speed(maxHz int64) error {
+	f.hz = maxHz
+	return nil
+}
+
+func (f *fakeConn) DevParams(maxHz int64, mode spi.Mode, bits int) error {
+	f.mode = mode
+	f.bits = bits
+	return nil
+}
+
+func (f *fakeConn) Tx(w, r []byte) error {
+	if f.cs != nil && f.cs.L != gpio.Low {
+		return errors.New("CS not asserted")
+	}
+	f.w = w
+	copy(r, w)
+	return f.err
+}
+
+func (f *fakeConn) Duplex() conn.Duplex {
+	if f.duplex == conn.DuplexUnknown {
+		return conn.Full
+	}
+	return f.duplex
+}
+
+// fakeBus implements spi.ConnPackets and conn.Limits on top of fakeConn.
+type fakeBus struct {
+	fakeConn
+	p []spi.Packet
+}
+
+func (f *fakeBus) TxPackets(p []spi.Packet) error {
+	if f.cs != nil && f.cs.L != gpio.Low {
+		return errors.New("CS not asserted")
+	}
+	f.p = p
+	for i := range p {
+		copy(p[i].R, p[i].W)
+	}
+	return f.err
+}
+
+func (f *fakeBus) MaxTxSize() int {
+	return 4096
+}
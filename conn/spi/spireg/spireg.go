@@ -193,6 +193,18 @@ func Unregister(name string) error {
 	return nil
 }
 
+// Clear unregisters all the ports.
+//
+// It is called by periph.Shutdown() so drivers can register them again on the
+// next periph.Init().
+func Clear() {
+	mu.Lock()
+	defer mu.Unlock()
+	byName = map[string]*Ref{}
+	byNumber = map[int]*Ref{}
+	byAlias = map[string]*Ref{}
+}
+
 //
 
 var (
@@ -222,9 +222,5 @@ func (f *fakeBus) DevParams(maxHz int64, mode spi.Mode, bits int) error {
 }
 
 func reset() {
-	mu.Lock()
-	defer mu.Unlock()
-	byName = map[string]*Ref{}
-	byNumber = map[int]*Ref{}
-	byAlias = map[string]*Ref{}
+	Clear()
 }
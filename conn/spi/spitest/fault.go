@@ -0,0 +1,99 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package spitest
+
+import (
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/conntest"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/spi"
+)
+
+// Fault implements spi.Conn and injects faults in the transactions done on
+// Conn.
+//
+// See conntest.Faults for the faults that can be injected.
+type Fault struct {
+	conntest.Faults
+	Conn spi.Conn
+}
+
+func (f *Fault) String() string {
+	return "fault"
+}
+
+// Tx implements spi.Conn.
+func (f *Fault) Tx(w, r []byte) error {
+	if err := f.Inject(); err != nil {
+		return err
+	}
+	if err := f.Conn.Tx(w, r); err != nil {
+		return err
+	}
+	f.Corrupt(r)
+	return nil
+}
+
+// TxPackets implements spi.ConnPackets.
+//
+// A fault fails the whole transaction.
+func (f *Fault) TxPackets(p []spi.Packet) error {
+	if err := f.Inject(); err != nil {
+		return err
+	}
+	if err := spi.TxPackets(f.Conn, p); err != nil {
+		return err
+	}
+	for i := range p {
+		f.Corrupt(p[i].R)
+	}
+	return nil
+}
+
+// Duplex implements spi.Conn.
+func (f *Fault) Duplex() conn.Duplex {
+	return f.Conn.Duplex()
+}
+
+// DevParams implements spi.Conn.
+func (f *Fault) DevParams(maxHz int64, mode spi.Mode, bits int) error {
+	return f.Conn.DevParams(maxHz, mode, bits)
+}
+
+// CLK implements spi.Pins.
+func (f *Fault) CLK() gpio.PinOut {
+	if p, ok := f.Conn.(spi.Pins); ok {
+		return p.CLK()
+	}
+	return gpio.INVALID
+}
+
+// MOSI implements spi.Pins.
+func (f *Fault) MOSI() gpio.PinOut {
+	if p, ok := f.Conn.(spi.Pins); ok {
+		return p.MOSI()
+	}
+	return gpio.INVALID
+}
+
+// MISO implements spi.Pins.
+func (f *Fault) MISO() gpio.PinIn {
+	if p, ok := f.Conn.(spi.Pins); ok {
+		return p.MISO()
+	}
+	return gpio.INVALID
+}
+
+// CS implements spi.Pins.
+func (f *Fault) CS() gpio.PinOut {
+	if p, ok := f.Conn.(spi.Pins); ok {
+		return p.CS()
+	}
+	return gpio.INVALID
+}
+
+var _ spi.Conn = &Fault{}
+var _ spi.Pins = &Fault{}
+var _ spi.ConnPackets = &Fault{}
@@ -0,0 +1,71 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package spitest
+
+import (
+	"testing"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/conntest"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/spi"
+)
+
+func TestFault(t *testing.T) {
+	p := &Playback{
+		Playback: conntest.Playback{
+			Ops: []conntest.IO{
+				{Write: []byte{1}, Read: []byte{2}},
+				{Write: []byte{3}, Read: []byte{4}},
+			},
+			D: conn.Full,
+		},
+		CSPin: &gpiotest.Pin{N: "CS"},
+	}
+	f := &Fault{Faults: conntest.Faults{Every: 2}, Conn: p}
+	if s := f.String(); s != "fault" {
+		t.Fatal(s)
+	}
+	if d := f.Duplex(); d != conn.Full {
+		t.Fatal(d)
+	}
+	if err := f.DevParams(1000, spi.Mode0, 8); err != nil {
+		t.Fatal(err)
+	}
+	if c := f.CS(); c.Name() != "CS" {
+		t.Fatal(c)
+	}
+	r := make([]byte, 1)
+	if err := f.Tx([]byte{1}, r); err != nil || r[0] != 2 {
+		t.Fatal(err, r)
+	}
+	// The failing transaction doesn't reach the connection.
+	if err := f.Tx([]byte{3}, r); err == nil {
+		t.Fatal("expected injected fault")
+	}
+	if err := f.Tx([]byte{3}, r); err != nil || r[0] != 4 {
+		t.Fatal(err, r)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFault_noPins(t *testing.T) {
+	f := &Fault{Conn: &Record{}}
+	if p := f.CLK(); p != gpio.INVALID {
+		t.Fatal(p)
+	}
+	if p := f.MOSI(); p != gpio.INVALID {
+		t.Fatal(p)
+	}
+	if p := f.MISO(); p != gpio.INVALID {
+		t.Fatal(p)
+	}
+	if p := f.CS(); p != gpio.INVALID {
+		t.Fatal(p)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package spitest
+
+import (
+	"os"
+
+	"periph.io/x/periph/conn/conntest"
+)
+
+// LoadPlayback returns a Playback with the operations read from the golden
+// file at path.
+//
+// The golden file format is the one of package conntest.
+func LoadPlayback(path string) (*Playback, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ops, err := conntest.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return &Playback{Playback: conntest.Playback{Ops: ops}}, nil
+}
+
+// Save writes the recorded operations to the golden file at path.
+//
+// The golden file format is the one of package conntest.
+func (r *Record) Save(path string) error {
+	r.Lock()
+	defer r.Unlock()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := conntest.Encode(f, r.Ops); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
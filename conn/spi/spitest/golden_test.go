@@ -0,0 +1,44 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package spitest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"periph.io/x/periph/conn/conntest"
+)
+
+func TestRecord_Save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "golden.txt")
+	r := Record{Conn: &Playback{Playback: conntest.Playback{Ops: []conntest.IO{{Write: []byte{1}, Read: []byte{2}}}}}}
+	if err := r.Tx([]byte{1}, make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPlayback(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := make([]byte, 1)
+	if err := p.Tx([]byte{1}, v); err != nil {
+		t.Fatal(err)
+	}
+	if v[0] != 2 {
+		t.Fatal(v)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -70,13 +70,34 @@ func (r *Record) Tx(w, read []byte) error {
 			return err
 		}
 	}
-	io := conntest.IO{Write: make([]byte, len(w))}
-	if len(read) != 0 {
-		io.Read = make([]byte, len(read))
+	r.record(w, read)
+	return nil
+}
+
+// TxPackets implements spi.ConnPackets.
+//
+// Each packet is recorded as one operation. The other fields of the packets
+// are not recorded.
+func (r *Record) TxPackets(p []spi.Packet) error {
+	if err := spi.ValidatePackets(p); err != nil {
+		return err
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.Conn == nil {
+		for i := range p {
+			if len(p[i].R) != 0 {
+				return errors.New("spitest: read unsupported when no bus is connected")
+			}
+		}
+	} else {
+		if err := spi.TxPackets(r.Conn, p); err != nil {
+			return err
+		}
+	}
+	for i := range p {
+		r.record(p[i].W, p[i].R)
 	}
-	copy(io.Write, w)
-	copy(io.Read, read)
-	r.Ops = append(r.Ops, io)
 	return nil
 }
 
@@ -163,6 +184,22 @@ func (p *Playback) Close() error {
 	return p.Playback.Close()
 }
 
+// TxPackets implements spi.ConnPackets.
+//
+// Each packet is played back as one operation. The other fields of the packets
+// are ignored.
+func (p *Playback) TxPackets(pkts []spi.Packet) error {
+	if err := spi.ValidatePackets(pkts); err != nil {
+		return err
+	}
+	for i := range pkts {
+		if err := p.Playback.Tx(pkts[i].W, pkts[i].R); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Speed implements spi.ConnCloser.
 func (p *Playback) Speed(maxHz int64) error {
 	return nil
@@ -226,6 +263,13 @@ func (l *Log) Tx(w, r []byte) error {
 	return err
 }
 
+// TxPackets implements spi.ConnPackets.
+func (l *Log) TxPackets(p []spi.Packet) error {
+	err := spi.TxPackets(l.Conn, p)
+	log.Printf("%s.TxPackets(%#v) = %v", l.Conn, p, err)
+	return err
+}
+
 // Duplex implements spi.Conn.
 func (l *Log) Duplex() conn.Duplex {
 	return l.Conn.Duplex()
@@ -233,7 +277,21 @@ func (l *Log) Duplex() conn.Duplex {
 
 //
 
+// record appends an operation; r must be locked.
+func (r *Record) record(w, read []byte) {
+	io := conntest.IO{Write: make([]byte, len(w))}
+	if len(read) != 0 {
+		io.Read = make([]byte, len(read))
+	}
+	copy(io.Write, w)
+	copy(io.Read, read)
+	r.Ops = append(r.Ops, io)
+}
+
 var _ spi.Conn = &RecordRaw{}
 var _ spi.Conn = &Record{}
 var _ spi.Pins = &Record{}
 var _ spi.Conn = &Playback{}
+var _ spi.ConnPackets = &Record{}
+var _ spi.ConnPackets = &Playback{}
+var _ spi.ConnPackets = &Log{}
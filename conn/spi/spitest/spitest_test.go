@@ -7,6 +7,7 @@ package spitest
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"periph.io/x/periph/conn"
 	"periph.io/x/periph/conn/conntest"
@@ -208,6 +209,47 @@ func TestRecord_Playback(t *testing.T) {
 	}
 }
 
+func TestRecord_Playback_packets(t *testing.T) {
+	r := Record{
+		Conn: &Playback{
+			Playback: conntest.Playback{
+				Ops: []conntest.IO{
+					{Write: []byte{0x03, 0x00}},
+					{Write: []byte{0, 0}, Read: []byte{1, 2}},
+				},
+				D: conn.Full,
+			},
+		},
+	}
+	v := make([]byte, 2)
+	p := []spi.Packet{{W: []byte{0x03, 0x00}, Hz: 1000}, {W: []byte{0, 0}, R: v, Delay: time.Microsecond}}
+	if err := r.TxPackets(p); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v, []byte{1, 2}) {
+		t.Fatal(v)
+	}
+	if len(r.Ops) != 2 || !bytes.Equal(r.Ops[0].Write, []byte{0x03, 0x00}) || !bytes.Equal(r.Ops[1].Read, []byte{1, 2}) {
+		t.Fatal(r.Ops)
+	}
+	if r.TxPackets(p) == nil {
+		t.Fatal("Playback.Ops is empty")
+	}
+	if r.TxPackets(nil) == nil {
+		t.Fatal("no packet")
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w := Record{}
+	if w.TxPackets([]spi.Packet{{R: v}}) == nil {
+		t.Fatal("read unsupported when no bus is connected")
+	}
+	if err := w.TxPackets([]spi.Packet{{W: []byte{1}}, {W: []byte{2}}}); err != nil || len(w.Ops) != 2 {
+		t.Fatal(err, w.Ops)
+	}
+}
+
 func TestLog_Playback(t *testing.T) {
 	r := Log{
 		Conn: &Playback{
@@ -293,6 +293,62 @@ func TestI2CSense_success(t *testing.T) {
 	}
 }
 
+func TestI2CSense_golden(t *testing.T) {
+	bus, err := i2ctest.LoadPlayback("testdata/i2c_sense.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, err := NewI2C(bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := devices.Environment{}
+	if err := dev.Sense(&env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Temperature != 23720 || env.Pressure != 100943 || env.Humidity != 6531 {
+		t.Fatalf("unexpected %#v", env)
+	}
+	if err := dev.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestI2CSense_registerMap(t *testing.T) {
+	// Model of the device, using the same data as TestI2CSense_success.
+	m := &i2ctest.RegisterMap{AutoIncrement: true, WritePairs: true}
+	m.Regs[0xD0] = 0x60
+	copy(m.Regs[0x88:], []byte{0x10, 0x6e, 0x6c, 0x66, 0x32, 0x0, 0x5d, 0x95, 0xb8, 0xd5, 0xd0, 0xb, 0x77, 0x1e, 0x9d, 0xff, 0xf9, 0xff, 0xac, 0x26, 0xa, 0xd8, 0xbd, 0x10, 0x0, 0x4b})
+	copy(m.Regs[0xE1:], []byte{0x6e, 0x1, 0x0, 0x13, 0x5, 0x0, 0x1e})
+	copy(m.Regs[0xF7:], []byte{0x4a, 0x52, 0xc0, 0x80, 0x96, 0xc0, 0x7a, 0x76})
+	// Only the 3 lower bits of ctrl_hum are writable.
+	m.ReadOnly[0xF2] = 0xF8
+	bus := i2ctest.Bus{Devices: map[uint16]i2ctest.Device{0x76: m}}
+	dev, err := NewI2C(&bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Regs[0xF4] != 0x6f || m.Regs[0xF2] != 0x3 || m.Regs[0xF5] != 0xe0 {
+		t.Fatalf("unexpected configuration %#v", m.Regs[0xF2:0xF6])
+	}
+	env := devices.Environment{}
+	if err := dev.Sense(&env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Temperature != 23720 || env.Pressure != 100943 || env.Humidity != 6531 {
+		t.Fatalf("unexpected %#v", env)
+	}
+	if err := dev.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Regs[0xF4] != 0 {
+		t.Fatalf("expected sleep mode, got 0x%x", m.Regs[0xF4])
+	}
+}
+
 func TestCalibrationFloat(t *testing.T) {
 	// Real data extracted from measurements from this device.
 	tRaw := int32(524112)
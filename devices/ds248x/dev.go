@@ -5,6 +5,7 @@
 package ds248x
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -51,6 +52,19 @@ func (d *Dev) Close() error {
 // A strong pull-up is typically required to power temperature conversion
 // or EEPROM writes.
 func (d *Dev) Tx(w, r []byte, power onewire.Pullup) error {
+	return d.TxContext(context.Background(), w, r, power)
+}
+
+// TxContext performs a bus transaction like Tx() unless ctx is done;
+// implements onewire.BusContext.
+//
+// ctx is checked between each byte. A cancelled transaction leaves the 1-wire
+// bus in an undefined state until the reset issued by the next transaction.
+// It is not a persistent error.
+func (d *Dev) TxContext(ctx context.Context, w, r []byte, power onewire.Pullup) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	d.Lock()
 	defer d.Unlock()
 
@@ -63,6 +77,9 @@ func (d *Dev) Tx(w, r []byte, power onewire.Pullup) error {
 
 	// Send bytes onto 1-wire bus.
 	for i, b := range w {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if power == onewire.StrongPullup && i == len(w)-1 && len(r) == 0 {
 			// This is the last byte, need to activate strong pull-up.
 			d.i2cTx([]byte{cmdWriteConfig, d.confReg&0xbf | 0x4}, nil)
@@ -73,6 +90,9 @@ func (d *Dev) Tx(w, r []byte, power onewire.Pullup) error {
 
 	// Read bytes from one-wire bus.
 	for i := range r {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if power == onewire.StrongPullup && i == len(r)-1 {
 			// This is the last byte, need to activate strong-pull-up
 			d.i2cTx([]byte{cmdWriteConfig, d.confReg&0xbf | 0x4}, nil)
@@ -191,3 +211,6 @@ type busError string
 
 func (e busError) Error() string  { return string(e) }
 func (e busError) BusError() bool { return true }
+
+var _ onewire.Bus = &Dev{}
+var _ onewire.BusContext = &Dev{}
@@ -5,11 +5,13 @@
 package ds248x
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
 	"periph.io/x/periph/conn/i2c/i2creg"
 	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/onewire"
 )
 
 // TestInit tests the initialization of a ds2483 using a recording.
@@ -30,6 +32,30 @@ func TestInit(t *testing.T) {
 	}
 }
 
+func TestTxContext_cancelled(t *testing.T) {
+	var ops = []i2ctest.IO{
+		{Addr: 0x18, Write: []byte{0xf0}, Read: []byte(nil)},
+		{Addr: 0x18, Write: []byte{0xe1, 0xf0}, Read: []byte{0x18}},
+		{Addr: 0x18, Write: []byte{0xd2, 0xe1}, Read: []byte{0x1}},
+		{Addr: 0x18, Write: []byte{0xe1, 0xb4}, Read: []byte(nil)},
+		{Addr: 0x18, Write: []byte{0xc3, 0x6, 0x26, 0x46, 0x66, 0x86}, Read: []byte(nil)},
+	}
+	bus := &i2ctest.Playback{Ops: ops}
+	d, err := New(bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.TxContext(ctx, []byte{0xcc}, nil, onewire.WeakPullup); err != context.Canceled {
+		t.Fatal(err)
+	}
+	// The cancellation is not a persistent error.
+	if d.err != nil {
+		t.Fatal(d.err)
+	}
+}
+
 func Example() {
 	// Open the I²C bus to which the DS248x is connected.
 	i2cBus, err := i2creg.Open("")
@@ -9,6 +9,7 @@
 package bitbang
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime"
@@ -23,6 +24,10 @@ import (
 // SkipAddr can be used to skip the address from being sent.
 const SkipAddr uint16 = 0xFFFF
 
+// clockStretchTimeout is the longest a device can hold SCL low. It matches the
+// SMBus timeout.
+const clockStretchTimeout = 35 * time.Millisecond
+
 // I2C represents an I²C master implemented as bit-banging on 2 GPIO pins.
 type I2C struct {
 	mu        sync.Mutex
@@ -42,6 +47,17 @@ func (i *I2C) Close() error {
 
 // Tx implements i2c.Bus.
 func (i *I2C) Tx(addr uint16, w, r []byte) error {
+	return i.TxContext(context.Background(), addr, w, r)
+}
+
+// TxContext implements i2c.BusContext.
+//
+// ctx is checked between each byte. When the transaction is cancelled, a STOP
+// condition is sent and ctx.Err() is returned.
+func (i *I2C) TxContext(ctx context.Context, addr uint16, w, r []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	i.mu.Lock()
 	defer i.mu.Unlock()
 	runtime.LockOSThread()
@@ -56,29 +72,33 @@ func (i *I2C) Tx(addr uint16, w, r []byte) error {
 			// TODO(maruel): Implement if desired; prefix 0b11110xx.
 			return errors.New("bitbang-i2c: invalid address")
 		}
-		// Page 13, section 3.1.10 The slave address and R/W bit
-		addr <<= 1
-		if len(r) == 0 {
-			addr |= 1
-		}
-		ack, err := i.writeByte(byte(addr))
-		if err != nil {
+		// When there is data to write, the read is done after a repeated START.
+		if err := i.writeAddr(addr, len(w) == 0 && len(r) != 0); err != nil {
 			return err
 		}
-		if !ack {
-			return errors.New("bitbang-i2c: got NACK")
-		}
 	}
 	for _, b := range w {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		ack, err := i.writeByte(b)
 		if err != nil {
 			return err
 		}
 		if !ack {
-			return errors.New("bitbang-i2c: got NACK")
+			return dataNACKError("bitbang-i2c: data got NACK")
+		}
+	}
+	if addr != SkipAddr && len(w) != 0 && len(r) != 0 {
+		i.restart()
+		if err := i.writeAddr(addr, true); err != nil {
+			return err
 		}
 	}
 	for x := range r {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		var err error
 		r[x], err = i.readByte()
 		if err != nil {
@@ -96,6 +116,16 @@ func (i *I2C) Speed(hz int64) error {
 	return nil
 }
 
+// Recover implements i2c.BusRecoverer.
+func (i *I2C) Recover() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err := i2c.Recover(i.scl, i.sda); err != nil {
+		return fmt.Errorf("bitbang-i2c: %v", err)
+	}
+	return nil
+}
+
 // SCL implements i2c.Pins.
 func (i *I2C) SCL() gpio.PinIO {
 	return i.scl
@@ -155,6 +185,22 @@ func (i *I2C) start() {
 	i.scl.Out(gpio.Low)
 }
 
+// restart sends a repeated START condition.
+//
+// Expects SDA low and SCL low.
+//
+// Ends with SDA and SCL low.
+//
+// Lasts 3/2 cycle.
+func (i *I2C) restart() {
+	// Page 9, section 3.1.4 START and STOP conditions
+	i.sda.Out(gpio.High)
+	i.sleepHalfCycle()
+	i.scl.Out(gpio.High)
+	i.sleepHalfCycle()
+	i.start()
+}
+
 // "When CLK is a high level and DIO changes from low level to high level, data
 // input ends."
 //
@@ -171,6 +217,25 @@ func (i *I2C) stop() {
 	i.sleepHalfCycle()
 }
 
+// writeAddr writes the 7 bits address followed by the R/W bit, which is 1 for
+// a read, then waits for ACK.
+//
+// Page 13, section 3.1.10 The slave address and R/W bit
+func (i *I2C) writeAddr(addr uint16, read bool) error {
+	b := byte(addr << 1)
+	if read {
+		b |= 1
+	}
+	ack, err := i.writeByte(b)
+	if err != nil {
+		return err
+	}
+	if !ack {
+		return addressNACKError(fmt.Sprintf("bitbang-i2c: address 0x%02X got NACK", addr))
+	}
+	return nil
+}
+
 // writeByte writes 8 bits then waits for ACK.
 //
 // Expects SDA and SCL low.
@@ -204,8 +269,10 @@ func (i *I2C) writeByte(b byte) (bool, error) {
 		return false, err
 	}
 	// Implement clock stretching, the device may keep the line low.
-	for i.scl.Read() == gpio.Low {
-		i.sleepHalfCycle()
+	for start := time.Now(); i.scl.Read() == gpio.Low; i.sleepHalfCycle() {
+		if time.Since(start) > clockStretchTimeout {
+			return false, timeoutError("bitbang-i2c: clock stretched for too long")
+		}
 	}
 	// ACK == Low.
 	ack := i.sda.Read() == gpio.Low
@@ -254,4 +321,27 @@ func (i *I2C) sleepHalfCycle() {
 	cpu.Nanospin(i.halfCycle)
 }
 
+// addressNACKError implements error and i2c.AddressNACKError.
+type addressNACKError string
+
+func (e addressNACKError) Error() string     { return string(e) }
+func (e addressNACKError) AddressNACK() bool { return true }
+
+// dataNACKError implements error and i2c.DataNACKError.
+type dataNACKError string
+
+func (e dataNACKError) Error() string  { return string(e) }
+func (e dataNACKError) DataNACK() bool { return true }
+
+// timeoutError implements error and i2c.TimeoutError.
+type timeoutError string
+
+func (e timeoutError) Error() string { return string(e) }
+func (e timeoutError) Timeout() bool { return true }
+
 var _ i2c.Bus = &I2C{}
+var _ i2c.BusContext = &I2C{}
+var _ i2c.BusRecoverer = &I2C{}
+var _ i2c.AddressNACKError = addressNACKError("")
+var _ i2c.DataNACKError = dataNACKError("")
+var _ i2c.TimeoutError = timeoutError("")
@@ -0,0 +1,267 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Specification
+//
+// https://www.maximintegrated.com/en/app-notes/index.mvp/id/126
+// https://www.maximintegrated.com/en/app-notes/index.mvp/id/187
+
+package bitbang
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/onewire"
+	"periph.io/x/periph/conn/onewire/onewirereg"
+	"periph.io/x/periph/host/cpu"
+)
+
+// Standard speed timings, from AN126 table 2.
+const (
+	owA = 6 * time.Microsecond   // Write 1 and read: low time
+	owB = 64 * time.Microsecond  // Write 1: recovery time
+	owC = 60 * time.Microsecond  // Write 0: low time
+	owD = 10 * time.Microsecond  // Write 0: recovery time
+	owE = 9 * time.Microsecond   // Read: sample delay after releasing
+	owF = 55 * time.Microsecond  // Read: recovery time
+	owH = 480 * time.Microsecond // Reset: low time
+	owI = 70 * time.Microsecond  // Reset: presence sample delay after releasing
+	owJ = 410 * time.Microsecond // Reset: recovery time
+)
+
+// OneWire represents a 1-wire master implemented as bit-banging on a GPIO
+// pin.
+//
+// The pin must have an external pull-up resistor, typically 4.7kΩ. The line
+// is pulled low by driving the pin and released by switching the pin to
+// input, so the pin driver must be able to change the pin direction in well
+// under a microsecond, like the memory mapped bcm283x or allwinner drivers.
+//
+// The strong pull-up is done by driving the pin high until the next
+// transaction. This is enough to power a few parasitic devices, like DS18B20
+// during a temperature conversion.
+type OneWire struct {
+	mu   sync.Mutex
+	q    gpio.PinIO          // Data line
+	spin func(time.Duration) // cpu.Nanospin, except in tests
+}
+
+// NewOneWire returns an object that communicates 1-wire over a pin.
+func NewOneWire(q gpio.PinIO) (*OneWire, error) {
+	// The bus idles high.
+	if err := q.In(gpio.PullUp, gpio.NoEdge); err != nil {
+		return nil, err
+	}
+	return &OneWire{q: q, spin: cpu.Nanospin}, nil
+}
+
+func (o *OneWire) String() string {
+	return fmt.Sprintf("bitbang/onewire(%s)", o.q)
+}
+
+// Close implements onewire.BusCloser.
+func (o *OneWire) Close() error {
+	return nil
+}
+
+// Register registers the bus in onewirereg under the name returned by
+// String() and the optional aliases, so it can be retrieved with
+// onewirereg.Open().
+//
+// The bus is shared by all the handles returned by onewirereg.Open().
+func (o *OneWire) Register(aliases ...string) error {
+	return onewirereg.Register(o.String(), aliases, -1, func() (onewire.BusCloser, error) {
+		return o, nil
+	})
+}
+
+// Tx implements onewire.Bus.
+func (o *OneWire) Tx(w, r []byte, power onewire.Pullup) error {
+	return o.TxContext(context.Background(), w, r, power)
+}
+
+// TxContext implements onewire.BusContext.
+//
+// ctx is checked between each byte. A cancelled transaction leaves the 1-wire
+// bus in an undefined state until the reset issued by the next transaction.
+func (o *OneWire) TxContext(ctx context.Context, w, r []byte, power onewire.Pullup) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if present, err := o.reset(); err != nil {
+		return err
+	} else if !present {
+		return noDevicesError("bitbang-onewire: no device present")
+	}
+	strong := power == onewire.StrongPullup
+	for i, b := range w {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		o.writeByte(b, strong && i == len(w)-1 && len(r) == 0)
+	}
+	for i := range r {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r[i] = o.readByte(strong && i == len(r)-1)
+	}
+	return nil
+}
+
+// Search implements onewire.Bus.
+func (o *OneWire) Search(alarmOnly bool) ([]onewire.Address, error) {
+	return onewire.Search(o, alarmOnly)
+}
+
+// SearchTriplet implements onewire.BusSearcher.
+//
+// It reads the bit and its complement then writes the direction taken.
+// SearchTriplet should not be used directly, use Search instead.
+func (o *OneWire) SearchTriplet(direction byte) (onewire.TripletResult, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// A device pulls the line low when the bit, then its complement, is 0.
+	tr := onewire.TripletResult{
+		GotZero: !o.readBit(false),
+		GotOne:  !o.readBit(false),
+	}
+	switch {
+	case tr.GotZero && tr.GotOne:
+		tr.Taken = direction & 1
+	case tr.GotZero:
+		tr.Taken = 0
+	default:
+		// Also when no device responded, in which case the search is aborted
+		// anyway.
+		tr.Taken = 1
+	}
+	o.writeBit(tr.Taken != 0, false)
+	return tr, nil
+}
+
+// Q implements onewire.Pins.
+func (o *OneWire) Q() gpio.PinIO {
+	return o.q
+}
+
+//
+
+// reset issues a reset signal on the 1-wire bus and returns true if any device
+// responded with a presence pulse.
+//
+// Lasts about 970µs.
+func (o *OneWire) reset() (bool, error) {
+	// Release the line, in case a strong pull-up was active, then make sure no
+	// device holds it low.
+	o.release()
+	o.spin(owA)
+	if o.q.Read() == gpio.Low {
+		return false, shortedBusError("bitbang-onewire: bus has a short")
+	}
+	o.q.Out(gpio.Low)
+	o.spin(owH)
+	o.release()
+	o.spin(owI)
+	present := o.q.Read() == gpio.Low
+	o.spin(owJ)
+	return present, nil
+}
+
+// writeByte writes b, least significant bit first. When strong is true, the
+// line is driven high after the last bit.
+func (o *OneWire) writeByte(b byte, strong bool) {
+	for i := uint(0); i < 8; i++ {
+		o.writeBit(b&(1<<i) != 0, strong && i == 7)
+	}
+}
+
+// readByte reads a byte, least significant bit first. When strong is true,
+// the line is driven high after the last bit.
+func (o *OneWire) readByte(strong bool) byte {
+	b := byte(0)
+	for i := uint(0); i < 8; i++ {
+		if o.readBit(strong && i == 7) {
+			b |= 1 << i
+		}
+	}
+	return b
+}
+
+// writeBit writes a single bit time slot. When strong is true, the line is
+// driven high instead of being released at the end of the slot.
+//
+// Lasts 70µs.
+func (o *OneWire) writeBit(b, strong bool) {
+	low, recovery := owC, owD
+	if b {
+		low, recovery = owA, owB
+	}
+	o.q.Out(gpio.Low)
+	o.spin(low)
+	if strong {
+		o.q.Out(gpio.High)
+	} else {
+		o.release()
+	}
+	o.spin(recovery)
+}
+
+// readBit reads a single bit time slot. When strong is true, the line is
+// driven high after sampling.
+//
+// Lasts 70µs.
+func (o *OneWire) readBit(strong bool) bool {
+	o.q.Out(gpio.Low)
+	o.spin(owA)
+	o.release()
+	o.spin(owE)
+	b := o.q.Read() == gpio.High
+	if strong {
+		o.q.Out(gpio.High)
+	}
+	o.spin(owF)
+	return b
+}
+
+// release lets the external pull-up resistor raise the line.
+func (o *OneWire) release() {
+	o.q.In(gpio.PullNoChange, gpio.NoEdge)
+}
+
+// shortedBusError implements error and onewire.ShortedBusError.
+type shortedBusError string
+
+func (e shortedBusError) Error() string   { return string(e) }
+func (e shortedBusError) IsShorted() bool { return true }
+func (e shortedBusError) BusError() bool  { return true }
+
+// noDevicesError implements error, onewire.NoDevicesError and
+// onewire.BusError.
+type noDevicesError string
+
+func (e noDevicesError) Error() string   { return string(e) }
+func (e noDevicesError) NoDevices() bool { return true }
+func (e noDevicesError) BusError() bool  { return true }
+
+var _ onewire.BusCloser = &OneWire{}
+var _ onewire.BusContext = &OneWire{}
+var _ onewire.BusSearcher = &OneWire{}
+var _ onewire.Pins = &OneWire{}
+var _ onewire.NoDevicesError = noDevicesError("")
+var _ onewire.ShortedBusError = shortedBusError("")
+var _ onewire.BusError = noDevicesError("")
@@ -0,0 +1,257 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package bitbang
+
+import (
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/onewire"
+)
+
+func TestOneWire_Tx(t *testing.T) {
+	// Bus not shorted, presence pulse, then 0xA5 least significant bit first.
+	p := &scriptedPin{reads: levels(true, false, true, false, true, false, false, true, false, true)}
+	o := newOneWire(t, p)
+	r := make([]byte, 1)
+	if err := o.Tx([]byte{0x01}, r, onewire.WeakPullup); err != nil {
+		t.Fatal(err)
+	}
+	if r[0] != 0xA5 {
+		t.Fatalf("0x%02X", r[0])
+	}
+	if b := p.written(); !equalBits(b, byteBits(0x01)) {
+		t.Fatal(b)
+	}
+	if n := p.count("High"); n != 0 {
+		t.Fatalf("unexpected strong pull-up: %v", p.levels())
+	}
+}
+
+func TestOneWire_Tx_strongWrite(t *testing.T) {
+	p := &scriptedPin{reads: levels(true, false)}
+	o := newOneWire(t, p)
+	if err := o.Tx([]byte{0x44, 0x80}, nil, onewire.StrongPullup); err != nil {
+		t.Fatal(err)
+	}
+	if b := p.written(); !equalBits(b, append(byteBits(0x44), byteBits(0x80)...)) {
+		t.Fatal(b)
+	}
+	// The line is driven high only at the end of the last bit.
+	l := p.levels()
+	if n := p.count("High"); n != 1 || l[len(l)-1] != "High" {
+		t.Fatal(l)
+	}
+}
+
+func TestOneWire_Tx_strongRead(t *testing.T) {
+	p := &scriptedPin{reads: levels(true, false)}
+	o := newOneWire(t, p)
+	r := make([]byte, 2)
+	if err := o.Tx([]byte{0xBE}, r, onewire.StrongPullup); err != nil {
+		t.Fatal(err)
+	}
+	if r[0] != 0xFF || r[1] != 0xFF {
+		t.Fatal(r)
+	}
+	// The line is driven high right after sampling the last bit read.
+	l := p.levels()
+	if n := p.count("High"); n != 1 || l[len(l)-1] != "High" || l[len(l)-2] != "Read" {
+		t.Fatal(l)
+	}
+}
+
+func TestOneWire_Tx_noDevice(t *testing.T) {
+	p := &scriptedPin{reads: levels(true, true)}
+	o := newOneWire(t, p)
+	err := o.Tx([]byte{0xCC}, nil, onewire.WeakPullup)
+	if e, ok := err.(onewire.NoDevicesError); !ok || !e.NoDevices() {
+		t.Fatal(err)
+	}
+	if len(p.written()) != 0 {
+		t.Fatal("unexpected write")
+	}
+}
+
+func TestOneWire_Tx_shorted(t *testing.T) {
+	p := &scriptedPin{reads: levels(false)}
+	o := newOneWire(t, p)
+	err := o.Tx([]byte{0xCC}, nil, onewire.WeakPullup)
+	if e, ok := err.(onewire.ShortedBusError); !ok || !e.IsShorted() {
+		t.Fatal(err)
+	}
+}
+
+func TestOneWire_SearchTriplet(t *testing.T) {
+	data := []struct {
+		bit, complement bool // Levels read
+		direction       byte
+		gotZero, gotOne bool
+		taken           byte
+	}{
+		// Devices with both values; the direction is followed.
+		{false, false, 0, true, true, 0},
+		{false, false, 1, true, true, 1},
+		// All the devices have a 0; the direction is ignored.
+		{false, true, 1, true, false, 0},
+		// All the devices have a 1; the direction is ignored.
+		{true, false, 0, false, true, 1},
+		// No device responded.
+		{true, true, 0, false, false, 1},
+	}
+	for i, line := range data {
+		p := &scriptedPin{reads: levels(line.bit, line.complement)}
+		o := newOneWire(t, p)
+		tr, err := o.SearchTriplet(line.direction)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tr.GotZero != line.gotZero || tr.GotOne != line.gotOne || tr.Taken != line.taken {
+			t.Fatalf("#%d: %#v", i, tr)
+		}
+		if b := p.written(); !equalBits(b, []bool{line.taken != 0}) {
+			t.Fatalf("#%d: %v", i, b)
+		}
+	}
+}
+
+func TestOneWire_String(t *testing.T) {
+	o := newOneWire(t, &scriptedPin{Pin: gpiotest.Pin{N: "GPIO4", Num: 4}})
+	if s := o.String(); s != "bitbang/onewire(GPIO4(4))" {
+		t.Fatal(s)
+	}
+	if o.Q() == nil {
+		t.Fatal("expected pin")
+	}
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//
+
+func newOneWire(t *testing.T, p *scriptedPin) *OneWire {
+	o, err := NewOneWire(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.spin = p.spin
+	p.ops = nil
+	return o
+}
+
+// op is an operation done on a scriptedPin.
+type op struct {
+	s string        // "In", "Low", "High", "Read" or "Spin"
+	d time.Duration // Duration of a "Spin"
+}
+
+// scriptedPin is a gpiotest.Pin that returns the levels in reads, then High,
+// and logs the operations done on it, including the waits.
+type scriptedPin struct {
+	gpiotest.Pin
+	reads []gpio.Level
+	ops   []op
+}
+
+func (s *scriptedPin) In(pull gpio.Pull, edge gpio.Edge) error {
+	s.ops = append(s.ops, op{s: "In"})
+	return nil
+}
+
+func (s *scriptedPin) Out(l gpio.Level) error {
+	name := "Low"
+	if l {
+		name = "High"
+	}
+	s.ops = append(s.ops, op{s: name})
+	return nil
+}
+
+func (s *scriptedPin) Read() gpio.Level {
+	s.ops = append(s.ops, op{s: "Read"})
+	if len(s.reads) == 0 {
+		return gpio.High
+	}
+	l := s.reads[0]
+	s.reads = s.reads[1:]
+	return l
+}
+
+func (s *scriptedPin) spin(d time.Duration) {
+	s.ops = append(s.ops, op{"Spin", d})
+}
+
+// written decodes the bits written from the log.
+//
+// A 0 holds the line low for owC. A 1 releases the line after owA, then waits
+// owB, while a read waits owE before sampling.
+func (s *scriptedPin) written() []bool {
+	var out []bool
+	for i := 0; i+3 < len(s.ops); i++ {
+		if s.ops[i].s != "Low" {
+			continue
+		}
+		if d := s.ops[i+1].d; d == owC {
+			out = append(out, false)
+		} else if d == owA && s.ops[i+3].d == owB {
+			out = append(out, true)
+		}
+	}
+	return out
+}
+
+// levels returns the log without the waits.
+func (s *scriptedPin) levels() []string {
+	var out []string
+	for _, o := range s.ops {
+		if o.s != "Spin" {
+			out = append(out, o.s)
+		}
+	}
+	return out
+}
+
+func (s *scriptedPin) count(name string) int {
+	n := 0
+	for _, o := range s.ops {
+		if o.s == name {
+			n++
+		}
+	}
+	return n
+}
+
+func levels(l ...bool) []gpio.Level {
+	out := make([]gpio.Level, len(l))
+	for i := range l {
+		out[i] = gpio.Level(l[i])
+	}
+	return out
+}
+
+// byteBits returns the bits of b in the order they are sent, least
+// significant bit first.
+func byteBits(b byte) []bool {
+	out := make([]bool, 8)
+	for i := range out {
+		out[i] = b&(1<<uint(i)) != 0
+	}
+	return out
+}
+
+func equalBits(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
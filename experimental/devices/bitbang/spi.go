@@ -12,6 +12,7 @@
 package bitbang
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -24,6 +25,9 @@ import (
 )
 
 // SPI represents a SPI master implemented as bit-banging on 3 or 4 GPIO pins.
+//
+// In spi.HalfDuplex mode, data is both sent and received on the MOSI pin,
+// which then must implement gpio.PinIO.
 type SPI struct {
 	sck gpio.PinOut // Clock
 	sdi gpio.PinIn  // MISO
@@ -49,7 +53,11 @@ func (s *SPI) Close() error {
 
 // Duplex implements spi.Conn.
 func (s *SPI) Duplex() conn.Duplex {
-	// Maybe implement bitbanging SPI only in half mode?
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mode&spi.HalfDuplex != 0 {
+		return conn.Half
+	}
 	return conn.Full
 }
 
@@ -72,8 +80,16 @@ func (s *SPI) DevParams(maxHz int64, mode spi.Mode, bits int) error {
 	if maxHz < 0 {
 		return errors.New("bitbang-spi: invalid maxHz")
 	}
-	if mode != spi.Mode3 {
-		return errors.New("bitbang-spi: not implemented")
+	if mode&^(spi.Mode3|spi.HalfDuplex|spi.LSBFirst|spi.NoCS) != 0 {
+		return fmt.Errorf("bitbang-spi: invalid mode 0x%x", int(mode))
+	}
+	if bits < 1 || bits > 32 {
+		return fmt.Errorf("bitbang-spi: invalid bits %d", bits)
+	}
+	if mode&spi.HalfDuplex != 0 {
+		if _, ok := s.sdo.(gpio.PinIO); !ok {
+			return errors.New("bitbang-spi: half-duplex requires MOSI to implement gpio.PinIO")
+		}
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -83,39 +99,93 @@ func (s *SPI) DevParams(maxHz int64, mode spi.Mode, bits int) error {
 	}
 	s.mode = mode
 	s.bits = bits
-	return nil
+	// Set the clock to its idle level.
+	return s.sck.Out(s.idle())
 }
 
 // Tx implements spi.Conn.
 //
-// BUG(maruel): Implement mode.
-// BUG(maruel): Implement bits.
+// In spi.HalfDuplex mode, w is sent first then r is read.
+//
 // BUG(maruel): Test if read works.
 func (s *SPI) Tx(w, r []byte) error {
+	return s.TxContext(context.Background(), w, r)
+}
+
+// TxContext implements conn.ConnContext.
+//
+// ctx is checked between each word. When the transfer is cancelled, CS is
+// deasserted and ctx.Err() is returned.
+func (s *SPI) TxContext(ctx context.Context, w, r []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mode&spi.HalfDuplex != 0 {
+		s.csOut(gpio.Low)
+		defer s.csOut(gpio.High)
+		if err := s.transfer(ctx, w, nil, s.bits); err != nil {
+			return err
+		}
+		return s.transfer(ctx, nil, r, s.bits)
+	}
 	if len(r) != 0 && len(w) != len(r) {
 		return errors.New("bitbang-spi: write and read buffers must be the same length")
 	}
+	s.csOut(gpio.Low)
+	defer s.csOut(gpio.High)
+	return s.transfer(ctx, w, r, s.bits)
+}
+
+// TxPackets implements spi.ConnPackets.
+//
+// Hz, BitsPerWord, Delay and CSChange are supported. In spi.HalfDuplex mode,
+// a packet cannot both write and read.
+func (s *SPI) TxPackets(p []spi.Packet) error {
+	if err := spi.ValidatePackets(p); err != nil {
+		return err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if s.csn != nil {
-		s.csn.Out(gpio.Low)
-		s.sleepHalfCycle()
+	for i := range p {
+		if p[i].BitsPerWord > 32 {
+			return fmt.Errorf("bitbang-spi: packet #%d: invalid bits %d", i, p[i].BitsPerWord)
+		}
+		if s.mode&spi.HalfDuplex != 0 && len(p[i].W) != 0 && len(p[i].R) != 0 {
+			return fmt.Errorf("bitbang-spi: packet #%d can't both write and read in half-duplex mode", i)
+		}
 	}
-	for i := uint(0); i < uint(len(w)*8); i++ {
-		s.sdo.Out(w[i/8]&(1<<(i%8)) != 0)
-		s.sleepHalfCycle()
-		s.sck.Out(gpio.Low)
-		s.sleepHalfCycle()
-		if len(r) != 0 {
-			if s.sdi.Read() == gpio.High {
-				r[i/8] |= 1 << (i % 8)
+	halfCycle := s.halfCycle
+	defer func() {
+		s.halfCycle = halfCycle
+	}()
+	s.csOut(gpio.Low)
+	for i := range p {
+		s.halfCycle = halfCycle
+		if p[i].Hz != 0 {
+			s.halfCycle = time.Second / time.Duration(p[i].Hz) / time.Duration(2)
+		}
+		bits := s.bits
+		if p[i].BitsPerWord != 0 {
+			bits = p[i].BitsPerWord
+		}
+		if err := s.transfer(context.Background(), p[i].W, p[i].R, bits); err != nil {
+			s.csOut(gpio.High)
+			return err
+		}
+		cpu.Nanospin(p[i].Delay)
+		if p[i].CSChange {
+			if i == len(p)-1 {
+				// Keep CS asserted after the transaction.
+				return nil
 			}
+			s.csOut(gpio.High)
+			s.sleepHalfCycle()
+			s.csOut(gpio.Low)
 		}
-		s.sck.Out(gpio.Low)
-	}
-	if s.csn != nil {
-		s.csn.Out(gpio.High)
 	}
+	s.csOut(gpio.High)
 	return nil
 }
 
@@ -184,9 +254,139 @@ func NewSPI(clk, mosi gpio.PinOut, miso gpio.PinIn, cs gpio.PinOut, speedHz int6
 
 //
 
+// csOut sets CS to l, if there is a CS pin and spi.NoCS isn't used. Asserting
+// CS lasts 1/2 cycle.
+func (s *SPI) csOut(l gpio.Level) {
+	if s.csn != nil && s.mode&spi.NoCS == 0 {
+		s.csn.Out(l)
+		if l == gpio.Low {
+			s.sleepHalfCycle()
+		}
+	}
+}
+
+// idle returns the clock level when idle, as defined by CPOL.
+func (s *SPI) idle() gpio.Level {
+	return s.mode&spi.Mode2 != 0
+}
+
+// transfer sends w and receives into r as words of the specified number of
+// bits. One of them can be empty, otherwise they must have the same length.
+// CS must be asserted.
+//
+// In half-duplex mode, only one of them can be specified and MOSI is switched
+// to input while reading.
+func (s *SPI) transfer(ctx context.Context, w, r []byte, bits int) error {
+	n := len(w)
+	if len(r) > n {
+		n = len(r)
+	}
+	size := wordSize(bits)
+	if n%size != 0 {
+		return fmt.Errorf("bitbang-spi: %d bytes is not a multiple of %d bytes words", n, size)
+	}
+	drive := true
+	var in gpio.PinIn
+	if len(r) != 0 {
+		in = s.sdi
+		if s.mode&spi.HalfDuplex != 0 {
+			p := s.sdo.(gpio.PinIO)
+			if err := p.In(gpio.PullUp, gpio.NoEdge); err != nil {
+				return err
+			}
+			in = p
+			drive = false
+		} else if in == nil {
+			return errors.New("bitbang-spi: can't read without a MISO pin")
+		}
+	}
+	for i := 0; i < n; i += size {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var v uint32
+		if len(w) != 0 {
+			v = getWord(w[i : i+size])
+		}
+		v = s.word(v, bits, drive, in)
+		if len(r) != 0 {
+			putWord(r[i:i+size], v)
+		}
+	}
+	return nil
+}
+
+// word clocks out the lowest bits of w when drive is true and returns the
+// bits read on in, if not nil.
+func (s *SPI) word(w uint32, bits int, drive bool, in gpio.PinIn) uint32 {
+	idle := s.idle()
+	cpha := s.mode&spi.Mode1 != 0
+	r := uint32(0)
+	for i := 0; i < bits; i++ {
+		bit := uint(bits - 1 - i)
+		if s.mode&spi.LSBFirst != 0 {
+			bit = uint(i)
+		}
+		if cpha {
+			// Data changes on the leading edge.
+			s.sck.Out(!idle)
+		}
+		if drive {
+			s.sdo.Out(w&(1<<bit) != 0)
+		}
+		s.sleepHalfCycle()
+		// Data is sampled on the leading edge for CPHA=0 and on the trailing
+		// edge for CPHA=1.
+		if cpha {
+			s.sck.Out(idle)
+		} else {
+			s.sck.Out(!idle)
+		}
+		if in != nil && in.Read() == gpio.High {
+			r |= 1 << bit
+		}
+		s.sleepHalfCycle()
+		if !cpha {
+			s.sck.Out(idle)
+		}
+	}
+	return r
+}
+
+// wordSize returns the number of bytes used to store a word of the specified
+// number of bits.
+func wordSize(bits int) int {
+	switch {
+	case bits <= 8:
+		return 1
+	case bits <= 16:
+		return 2
+	default:
+		return 4
+	}
+}
+
+// getWord returns the little endian word stored in b.
+func getWord(b []byte) uint32 {
+	v := uint32(0)
+	for i := range b {
+		v |= uint32(b[i]) << (8 * uint(i))
+	}
+	return v
+}
+
+// putWord stores v in little endian in b.
+func putWord(b []byte, v uint32) {
+	for i := range b {
+		b[i] = byte(v >> (8 * uint(i)))
+	}
+}
+
 // sleep does a busy loop to act as fast as possible.
 func (s *SPI) sleepHalfCycle() {
 	cpu.Nanospin(s.halfCycle)
 }
 
 var _ spi.Conn = &SPI{}
+var _ conn.ConnContext = &SPI{}
+var _ spi.ConnPackets = &SPI{}
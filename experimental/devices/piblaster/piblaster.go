@@ -7,6 +7,9 @@
 // See https://github.com/sarfata/pi-blaster for more details. This package
 // relies on pi-blaster being installed and enabled.
 //
+// bcm283x.Pin.PWM() supports any pin via DMA without requiring an external
+// daemon and should be preferred.
+//
 // TODO(maruel): "dtoverlay=pwm" or "dtoverlay=pwm-2chan" works without having
 // to install anything, albeit with less pins supported.
 //
@@ -20,6 +23,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"periph.io/x/periph/conn/gpio"
 )
@@ -38,6 +42,24 @@ func SetPWM(p gpio.PinIO, duty float32) error {
 	return err
 }
 
+// Period is the PWM period used by the pi-blaster daemon in its default
+// configuration. It can only be changed by recompiling pi-blaster.
+const Period = 10 * time.Millisecond
+
+// SetPWMPeriod is like SetPWM but also checks the desired period.
+//
+// pi-blaster runs all the pins at Period so it fails with any other period.
+// Returns the period actually used.
+func SetPWMPeriod(p gpio.PinIO, duty float32, period time.Duration) (time.Duration, error) {
+	if period != Period {
+		return 0, fmt.Errorf("piblaster: period %s is not supported, only %s is", period, Period)
+	}
+	if err := SetPWM(p, duty); err != nil {
+		return 0, err
+	}
+	return Period, nil
+}
+
 // ReleasePWM releases a GPIO output and leave it floating.
 //
 // This function must be called on process exit for each activated pin
@@ -18,11 +18,14 @@ import (
 
 	"periph.io/x/periph"
 	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/pin"
 	"periph.io/x/periph/host/pmem"
 )
 
 // driverGPIO implements periph.Driver.
 type driverGPIO struct {
+	// views are the memory mappings done in Init() to unmap in Close().
+	views []*pmem.View
 }
 
 func (d *driverGPIO) String() string {
@@ -47,28 +50,71 @@ func (d *driverGPIO) Init() (bool, error) {
 		}
 		return true, err
 	}
+	d.views = append(d.views, m)
 	if err := m.Struct(reflect.ValueOf(&gpioMemory)); err != nil {
 		return true, err
 	}
 
+	var pwmBase uint64
 	switch {
 	case IsA64():
 		mapA64Pins()
+		pwmBase = 0x01C21400
 	case IsR8():
 		mapR8Pins()
+		pwmBase = 0x01C20E00
 	default:
 		return false, errors.New("unknown Allwinner CPU model")
 	}
+	// Failing to map the PWM controller is not fatal, only PWM is unavailable.
+	if pwmMemory == nil {
+		_ = mapStruct(&d.views, pwmBase, reflect.ValueOf(&pwmMemory))
+	}
 
 	return true, initPins()
 }
 
+// Close unmaps the registers.
+func (d *driverGPIO) Close() error {
+	err := closeViews(d.views)
+	d.views = nil
+	gpioMemory = nil
+	pwmMemory = nil
+	return err
+}
+
 func init() {
 	if isArm {
 		periph.MustRegister(&driverGPIO{})
 	}
 }
 
+// mapStruct is like pmem.MapStruct() but appends the mapping to views so it
+// can be unmapped later.
+func mapStruct(views *[]*pmem.View, base uint64, v reflect.Value) error {
+	m, err := pmem.Map(base, int(v.Elem().Type().Elem().Size()))
+	if err != nil {
+		return err
+	}
+	if err := m.Struct(v); err != nil {
+		m.Close()
+		return err
+	}
+	*views = append(*views, m)
+	return nil
+}
+
+// closeViews unmaps all the views and returns the first error.
+func closeViews(views []*pmem.View) error {
+	var err error
+	for _, v := range views {
+		if err2 := v.Close(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
 // getBaseAddress queries the virtual file system to retrieve the base address
 // of the GPIO registers for GPIO pins in groups PB to PH.
 //
@@ -93,6 +139,11 @@ func getBaseAddress() uint64 {
 
 // Ensure that the various structs implement the interfaces they're supposed to.
 
+var _ periph.DriverCloser = &driverGPIO{}
+
 var _ gpio.PinIn = &Pin{}
 var _ gpio.PinOut = &Pin{}
 var _ gpio.PinIO = &Pin{}
+var _ gpio.PinPWM = &Pin{}
+var _ gpio.PinGrouper = &Pin{}
+var _ pin.PinFunc = &Pin{}
@@ -10,6 +10,7 @@ package allwinner
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"periph.io/x/periph/conn/gpio"
@@ -110,6 +111,37 @@ func (p *Pin) Function() string {
 	}
 }
 
+// SetFunction changes the pin function to f, as returned by Function();
+// implements pin.PinFunc.
+//
+// For "In/..." the pin is set as an input without changing its pull and for
+// "Out/..." it is set as an output at the specified level.
+func (p *Pin) SetFunction(f string) error {
+	if gpioMemory == nil {
+		return p.wrap(errors.New("subsystem not initialized"))
+	}
+	if !p.available {
+		return p.wrap(errors.New("not available on this CPU architecture"))
+	}
+	switch {
+	case strings.HasPrefix(f, "In/"):
+		return p.In(gpio.PullNoChange, gpio.NoEdge)
+	case strings.HasPrefix(f, "Out/"):
+		return p.Out(f == "Out/High")
+	case f == "<Disabled>":
+		p.setFunction(disabled)
+		return nil
+	}
+	alts := [...]function{alt1, alt2, alt3, alt4, alt5}
+	for i, name := range p.altFunc {
+		if (name != "" && f == name) || f == fmt.Sprintf("<Alt%d>", i+1) {
+			p.setFunction(alts[i])
+			return nil
+		}
+	}
+	return p.wrap(fmt.Errorf("unknown function %q", f))
+}
+
 // In sets the pin direction to input and optionally enables a pull-up/down
 // resistor as well as edge detection.
 //
@@ -223,12 +255,79 @@ func (p *Pin) Out(l gpio.Level) error {
 	return nil
 }
 
-// PWM is not supported.
+// PWM outputs a periodic signal on supported pins and implements
+// gpio.PinOut.
+//
+// Only the pin with the PWM0 alternate function is supported, i.e. PB2 on the
+// R8 and PD22 on the A64. The signal has a period of 100µs.
 func (p *Pin) PWM(duty int) error {
-	return p.wrap(errors.New("pwm is not supported"))
+	_, err := p.PWMPeriod(duty, pwmDefaultPeriod)
+	return err
+}
+
+// PWMPeriod is like PWM but with a specific period and implements
+// gpio.PinPWM.
+//
+// The prescaler is selected automatically for the highest resolution. The
+// period can be up to ~196s.
+func (p *Pin) PWMPeriod(duty int, period time.Duration) (time.Duration, error) {
+	if gpioMemory == nil {
+		return 0, p.wrap(errors.New("subsystem not initialized"))
+	}
+	if !p.available {
+		return 0, p.wrap(errors.New("not available on this CPU architecture"))
+	}
+	if p.altFunc[0] != "PWM0" {
+		return 0, p.wrap(errors.New("pwm is not supported on this pin"))
+	}
+	if pwmMemory == nil {
+		return 0, p.wrap(errors.New("pwm controller is not available"))
+	}
+	if duty < 0 || duty > gpio.Max {
+		return 0, p.wrap(fmt.Errorf("invalid duty %d", duty))
+	}
+	if p.usingEdge {
+		// First disable edges.
+		if err := p.edge.In(gpio.PullNoChange, gpio.NoEdge); err != nil {
+			return 0, p.wrap(err)
+		}
+		p.usingEdge = false
+	}
+	d, err := pwmMemory.set(period, duty)
+	if err != nil {
+		return 0, p.wrap(err)
+	}
+	p.setFunction(alt1)
+	return d, nil
 }
 
+// Group returns a gpio.Group that reads and writes all the pins at once and
+// implements gpio.PinGrouper.
 //
+// Returns nil if the pins are not all in the same group, e.g. PB.
+func (p *Pin) Group(pins []gpio.PinIO) gpio.Group {
+	return newGroup(pins)
+}
+
+//
+
+// data returns the data register of the pin's group.
+func (p *Pin) data() *uint32 {
+	if gpioMemory == nil || !p.available {
+		return nil
+	}
+	return &gpioMemory.groups[p.group].data
+}
+
+// bit returns the bit of the pin in the data register.
+func (p *Pin) bit() uint32 {
+	return 1 << p.offset
+}
+
+// isOut returns true if the pin is an output without edge detection.
+func (p *Pin) isOut() bool {
+	return !p.usingEdge && p.function() == out
+}
 
 // function returns the current GPIO pin function.
 func (p *Pin) function() function {
@@ -286,6 +385,10 @@ const (
 
 var gpioMemory *gpioMap
 
+// pwmMemory is the PWM controller connected to the pin with the PWM0
+// alternate function.
+var pwmMemory *pwmMap
+
 // cpupins that may be implemented by a generic Allwinner CPU. Not all pins
 // will be present on all models and even if the CPU model supports them they
 // may not be connected to anything on the board. The net effect is that it may
@@ -558,6 +661,98 @@ func initPins() error {
 	return nil
 }
 
+// groupPin is a pin that can be part of a group.
+type groupPin interface {
+	gpio.PinIO
+	data() *uint32
+	isOut() bool
+	bit() uint32
+}
+
+// group implements gpio.Group for pins in the same group by accessing the
+// data register once.
+type group struct {
+	pins []gpio.PinIO
+	real []groupPin
+	data *uint32
+}
+
+// newGroup returns a group if all the pins share the same data register.
+func newGroup(pins []gpio.PinIO) gpio.Group {
+	g := &group{pins: pins, real: make([]groupPin, len(pins))}
+	for i, pin := range pins {
+		r, ok := pin.(groupPin)
+		if !ok {
+			return nil
+		}
+		d := r.data()
+		if d == nil || (g.data != nil && d != g.data) {
+			return nil
+		}
+		g.data = d
+		g.real[i] = r
+	}
+	return g
+}
+
+func (g *group) String() string {
+	names := make([]string, len(g.pins))
+	for i, p := range g.pins {
+		names[i] = p.String()
+	}
+	return "Group(" + strings.Join(names, ", ") + ")"
+}
+
+func (g *group) Pins() []gpio.PinIO {
+	return g.pins
+}
+
+func (g *group) In(pull gpio.Pull) error {
+	for _, p := range g.real {
+		if err := p.In(pull, gpio.NoEdge); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read reads the data register once.
+func (g *group) Read() uint64 {
+	d := *g.data
+	var out uint64
+	for i, p := range g.real {
+		if d&p.bit() != 0 {
+			out |= 1 << uint(i)
+		}
+	}
+	return out
+}
+
+// Out writes the data register once.
+func (g *group) Out(value, mask uint64) error {
+	var set, clr uint32
+	for i, p := range g.real {
+		if mask&(1<<uint(i)) == 0 {
+			continue
+		}
+		if value&(1<<uint(i)) != 0 {
+			set |= p.bit()
+		} else {
+			clr |= p.bit()
+		}
+	}
+	*g.data = *g.data&^clr | set
+	// Pins that are not yet plain outputs are set up one by one.
+	for i, p := range g.real {
+		if mask&(1<<uint(i)) != 0 && !p.isOut() {
+			if err := p.Out(value&(1<<uint(i)) != 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // function encodes the active functionality of a pin. The alternate functions
 // are GPIO pin dependent.
 type function uint8
@@ -196,12 +196,78 @@ func (p *PinPL) Out(l gpio.Level) error {
 	return nil
 }
 
-// PWM implements gpio.PinOut.
+// PWM outputs a periodic signal on supported pins and implements
+// gpio.PinOut.
+//
+// Only PL10 is supported, via the S_PWM controller. The signal has a period
+// of 100µs.
 func (p *PinPL) PWM(duty int) error {
-	return p.wrap(errors.New("pwm is not supported"))
+	_, err := p.PWMPeriod(duty, pwmDefaultPeriod)
+	return err
+}
+
+// PWMPeriod is like PWM but with a specific period and implements
+// gpio.PinPWM.
+//
+// The prescaler is selected automatically for the highest resolution. The
+// period can be up to ~196s.
+func (p *PinPL) PWMPeriod(duty int, period time.Duration) (time.Duration, error) {
+	if gpioMemoryPL == nil {
+		return 0, p.wrap(errors.New("subsystem not initialized"))
+	}
+	if !p.supportPWM() {
+		return 0, p.wrap(errors.New("pwm is not supported on this pin"))
+	}
+	if pwmMemoryPL == nil {
+		return 0, p.wrap(errors.New("pwm controller is not available"))
+	}
+	if duty < 0 || duty > gpio.Max {
+		return 0, p.wrap(fmt.Errorf("invalid duty %d", duty))
+	}
+	if p.usingEdge {
+		// First disable edges.
+		if err := p.edge.In(gpio.PullNoChange, gpio.NoEdge); err != nil {
+			return 0, p.wrap(err)
+		}
+		p.usingEdge = false
+	}
+	d, err := pwmMemoryPL.set(period, duty)
+	if err != nil {
+		return 0, p.wrap(err)
+	}
+	if !p.setFunction(alt1) {
+		return 0, p.wrap(errors.New("failed to set pin as pwm"))
+	}
+	return d, nil
 }
 
+// Group returns a gpio.Group that reads and writes all the pins at once and
+// implements gpio.PinGrouper.
 //
+// Returns nil if the pins are not all in group PL.
+func (p *PinPL) Group(pins []gpio.PinIO) gpio.Group {
+	return newGroup(pins)
+}
+
+//
+
+// data returns the data register of group PL.
+func (p *PinPL) data() *uint32 {
+	if gpioMemoryPL == nil {
+		return nil
+	}
+	return &gpioMemoryPL.data
+}
+
+// bit returns the bit of the pin in the data register.
+func (p *PinPL) bit() uint32 {
+	return 1 << p.offset
+}
+
+// isOut returns true if the pin is an output without edge detection.
+func (p *PinPL) isOut() bool {
+	return !p.usingEdge && p.function() == out
+}
 
 // function returns the current GPIO pin function.
 func (p *PinPL) function() function {
@@ -214,15 +280,16 @@ func (p *PinPL) function() function {
 
 // setFunction changes the GPIO pin function.
 //
-// Returns false if the pin was in AltN. Only accepts in and out
+// Returns false if the pin was in AltN. Only accepts in, out and alt1 on the
+// PWM pin.
 func (p *PinPL) setFunction(f function) bool {
-	if f != in && f != out {
+	if f != in && f != out && (f != alt1 || !p.supportPWM()) {
 		return false
 	}
 	// Interrupt based edge triggering is Alt5 but this is only supported on some
 	// pins.
 	// TODO(maruel): This check should use a whitelist of pins.
-	if actual := p.function(); actual != in && actual != out && actual != disabled && actual != alt5 {
+	if actual := p.function(); actual != in && actual != out && actual != disabled && actual != alt5 && (actual != alt1 || !p.supportPWM()) {
 		// Pin is in special mode.
 		return false
 	}
@@ -239,6 +306,12 @@ func (p *PinPL) setFunction(f function) bool {
 	return true
 }
 
+// supportPWM returns true if the pin can be connected to the S_PWM
+// controller with alt1.
+func (p *PinPL) supportPWM() bool {
+	return mapping[p.offset][0] == "PWM0"
+}
+
 func (p *PinPL) wrap(err error) error {
 	return fmt.Errorf("allwinner-gpio-pl (%s): %v", p, err)
 }
@@ -267,6 +340,9 @@ var cpuPinsPL = []PinPL{
 // do not exist.
 var gpioMemoryPL *gpioGroup
 
+// pwmMemoryPL is the S_PWM controller connected to PL10.
+var pwmMemoryPL *pwmMap
+
 // See ../allwinner/allwinner.go for details.
 // TODO(maruel): Figure out what the S_ prefix means.
 var mapping = [13][5]string{
@@ -324,6 +400,8 @@ func getBaseAddressPL() uint64 {
 
 // driverGPIOPL implements periph.Driver.
 type driverGPIOPL struct {
+	// views are the memory mappings done in Init() to unmap in Close().
+	views []*pmem.View
 }
 
 func (d *driverGPIOPL) String() string {
@@ -346,9 +424,15 @@ func (d *driverGPIOPL) Init() (bool, error) {
 		}
 		return true, err
 	}
+	d.views = append(d.views, m)
 	if err := m.Struct(reflect.ValueOf(&gpioMemoryPL)); err != nil {
 		return true, err
 	}
+	// Failing to map the S_PWM controller is not fatal, only PWM is
+	// unavailable.
+	if pwmMemoryPL == nil {
+		_ = mapStruct(&d.views, 0x01F03800, reflect.ValueOf(&pwmMemoryPL))
+	}
 
 	for i := range cpuPinsPL {
 		p := &cpuPinsPL[i]
@@ -371,12 +455,25 @@ func (d *driverGPIOPL) Init() (bool, error) {
 	return true, nil
 }
 
+// Close unmaps the registers.
+func (d *driverGPIOPL) Close() error {
+	err := closeViews(d.views)
+	d.views = nil
+	gpioMemoryPL = nil
+	pwmMemoryPL = nil
+	return err
+}
+
 func init() {
 	if isArm {
 		periph.MustRegister(&driverGPIOPL{})
 	}
 }
 
+var _ periph.DriverCloser = &driverGPIOPL{}
+
 var _ gpio.PinIn = &PinPL{}
 var _ gpio.PinOut = &PinPL{}
 var _ gpio.PinIO = &PinPL{}
+var _ gpio.PinPWM = &PinPL{}
+var _ gpio.PinGrouper = &PinPL{}
@@ -0,0 +1,44 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package allwinner
+
+import (
+	"testing"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+func TestGroup(t *testing.T) {
+	defer func() {
+		gpioMemoryPL = nil
+	}()
+	if PL0.Group([]gpio.PinIO{PL0, PL1}) != nil {
+		t.Fatal("not initialized")
+	}
+	gpioMemoryPL = &gpioGroup{}
+	if PL0.Group([]gpio.PinIO{PL0, gpio.INVALID}) != nil {
+		t.Fatal("different drivers")
+	}
+	g, err := gpio.NewGroup(PL2, PL3, PL4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := g.String(); s != "Group(PL2(354), PL3(355), PL4(356))" {
+		t.Fatal(s)
+	}
+	gpioMemoryPL.data = 1 << 3
+	if err := g.Out(0x5, 0x7); err != nil {
+		t.Fatal(err)
+	}
+	if gpioMemoryPL.data != 1<<2|1<<4 {
+		t.Fatalf("unexpected data 0x%x", gpioMemoryPL.data)
+	}
+	if PL2.function() != out || PL3.function() != out || PL4.function() != out {
+		t.Fatal("expected pins as output")
+	}
+	if v := g.Read(); v != 0x5 {
+		t.Fatal(v)
+	}
+}
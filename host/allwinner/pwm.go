@@ -5,8 +5,12 @@
 package allwinner
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
 )
 
 const pwmClock = 24000000
@@ -163,3 +167,78 @@ func toPeriod(total uint32, active uint16) pwmPeriod {
 	}
 	return pwmPeriod(total-1)<<16 | pwmPeriod(active)
 }
+
+// pwmDefaultPeriod is the period used by PWM(); 10kHz with a resolution of
+// 2400 steps.
+const pwmDefaultPeriod = 100 * time.Microsecond
+
+// pwmMap is the memory mapped PWM controller.
+//
+// Only channel 0 is supported.
+//
+// A64: Page 194.
+// R8: Page 83.
+type pwmMap struct {
+	ctl    pwmCtl    // PWM_CTRL_REG
+	period pwmPeriod // PWM_CH0_PERIOD
+}
+
+// set enables channel 0 to output a signal of the specified period with the
+// duty cycle in [0, gpio.Max].
+//
+// The prescaler is selected automatically to get the highest resolution for
+// the period. Returns the actual period.
+func (p *pwmMap) set(period time.Duration, duty int) (time.Duration, error) {
+	scaler, total, err := pwmPrescaler(period)
+	if err != nil {
+		return 0, err
+	}
+	active := uint64(duty) * uint64(total) / gpio.Max
+	if active > 0xFFFF {
+		active = 0xFFFF
+	}
+	p.ctl = p.ctl&^pwm0Mask | pwm0SCLK | pwm0Polarity | pwm0Enable | pwmCtl(scaler)
+	// The period register can't be written while the controller is busy
+	// latching the previous value.
+	for i := 0; p.ctl&pwmBusy != 0; i++ {
+		if i == 1000 {
+			return 0, errors.New("pwm controller is stuck busy")
+		}
+		time.Sleep(time.Microsecond)
+	}
+	p.period = toPeriod(total, uint16(active))
+	return time.Duration(uint64(total) * uint64(time.Second) / uint64(pwmFreq(scaler))), nil
+}
+
+// pwmFreq returns the frequency of the PWM clock after the prescaler.
+func pwmFreq(scaler pwmPrescale) uint32 {
+	for _, p := range prescalers {
+		if p.scaler == scaler {
+			return p.freq
+		}
+	}
+	return 0
+}
+
+// pwmPrescaler returns the prescaler with the highest frequency that can
+// generate period, and the number of prescaled clock cycles in the period.
+func pwmPrescaler(period time.Duration) (pwmPrescale, uint32, error) {
+	if period <= 0 {
+		return 0, 0, fmt.Errorf("invalid period %s", period)
+	}
+	for _, p := range prescalers {
+		// Check before multiplying, which could overflow.
+		if uint64(period) > pwmMaxPeriod*uint64(time.Second)/uint64(p.freq) {
+			continue
+		}
+		total := uint64(period) * uint64(p.freq) / uint64(time.Second)
+		if total > pwmMaxPeriod {
+			continue
+		}
+		if total < 2 {
+			return 0, 0, fmt.Errorf("period %s is too short", period)
+		}
+		return p.scaler, uint32(total), nil
+	}
+	return 0, 0, fmt.Errorf("period %s is too long", period)
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package allwinner
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+func TestPWMPrescaler(t *testing.T) {
+	data := []struct {
+		period time.Duration
+		scaler pwmPrescale
+		total  uint32
+	}{
+		{100 * time.Microsecond, pwmPrescale1, 2400},
+		{2 * time.Millisecond, pwmPrescale1, 48000},
+		{20 * time.Millisecond, pwmPrescale120, 4000},
+		{time.Second, pwmPrescale480, 50000},
+		{10 * time.Second, pwmPrescale12000, 20000},
+		{196 * time.Second, pwmPrescale72000, 65268},
+	}
+	for i, line := range data {
+		scaler, total, err := pwmPrescaler(line.period)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if scaler != line.scaler || total != line.total {
+			t.Fatalf("#%d: expected %s/%d, got %s/%d", i, line.scaler, line.total, scaler, total)
+		}
+	}
+	// 768614336505ns would overflow the computation at the /1 prescaler.
+	for _, p := range []time.Duration{0, 50 * time.Nanosecond, 197 * time.Second, 768614336505, time.Hour, math.MaxInt64} {
+		if _, _, err := pwmPrescaler(p); err == nil {
+			t.Fatalf("%s: expected error", p)
+		}
+	}
+}
+
+func TestPWMMap_set(t *testing.T) {
+	p := pwmMap{}
+	if d, err := p.set(pwmDefaultPeriod, gpio.Half); err != nil || d != pwmDefaultPeriod {
+		t.Fatal(d, err)
+	}
+	if p.ctl != pwm0SCLK|pwm0Polarity|pwm0Enable|pwm0Prescale1 {
+		t.Fatal(p.ctl)
+	}
+	if p.period != toPeriod(2400, 1200) {
+		t.Fatal(p.period)
+	}
+	if d, err := p.set(time.Millisecond*2, gpio.Max); err != nil || d != 2*time.Millisecond {
+		t.Fatal(d, err)
+	}
+	if p.period != toPeriod(48000, 48000) {
+		t.Fatal(p.period)
+	}
+}
+
+func TestPinPL_PWM(t *testing.T) {
+	defer func() {
+		gpioMemoryPL = nil
+		pwmMemoryPL = nil
+	}()
+	if PL10.PWM(gpio.Half) == nil {
+		t.Fatal("subsystem not initialized")
+	}
+	gpioMemoryPL = &gpioGroup{}
+	if PL10.PWM(gpio.Half) == nil {
+		t.Fatal("pwm controller is not available")
+	}
+	pwmMemoryPL = &pwmMap{}
+	if PL9.PWM(gpio.Half) == nil {
+		t.Fatal("PL9 doesn't support PWM")
+	}
+	if PL10.PWM(-1) == nil {
+		t.Fatal("invalid duty")
+	}
+	if err := PL10.PWM(gpio.Half); err != nil {
+		t.Fatal(err)
+	}
+	if f := PL10.Function(); f != "PWM0" {
+		t.Fatal(f)
+	}
+	if pwmMemoryPL.period != toPeriod(2400, 1200) {
+		t.Fatal(pwmMemoryPL.period)
+	}
+	// A servo at 50Hz.
+	if d, err := PL10.PWMPeriod(gpio.Max/4, 20*time.Millisecond); err != nil || d != 20*time.Millisecond {
+		t.Fatal(d, err)
+	}
+	if pwmMemoryPL.period != toPeriod(4000, 1000) {
+		t.Fatal(pwmMemoryPL.period)
+	}
+	// Rounded to the prescaled clock resolution.
+	if d, err := PL10.PWMPeriod(gpio.Half, 1000020*time.Nanosecond); err != nil || d != 1000*time.Microsecond {
+		t.Fatal(d, err)
+	}
+	if err := PL10.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+}
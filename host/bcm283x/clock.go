@@ -4,6 +4,12 @@
 
 package bcm283x
 
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
 const (
 	// 31:24 password
 	passwdCtl clockCtl = 0x5A << 24 // PASSWD
@@ -52,3 +58,58 @@ const (
 //
 // Page 108
 type clockDiv uint32
+
+// clock is a pair of clockCtl / clockDiv.
+//
+// It can be set to one of the sources: srcOscillator (19.2MHz) and srcPLLD
+// (500MHz).
+type clock struct {
+	ctl clockCtl
+	div clockDiv
+}
+
+// set changes the clock source and integer divisor.
+//
+// It is a no-op if the clock is already running with this configuration, so
+// that a peripheral sharing the clock isn't glitched.
+func (c *clock) set(src clockCtl, divi uint32) error {
+	if divi == 0 || clockDiv(divi) > diviMax {
+		return fmt.Errorf("invalid clock divisor %d", divi)
+	}
+	div := clockDiv(divi) << diviShift
+	if c.ctl&(enabClk|srcMask) == enabClk|src && c.div&(diviMask|divfMask) == div {
+		return nil
+	}
+	// Stop the clock and wait for it to settle before changing anything else,
+	// otherwise the clock generator may lock up.
+	c.ctl = passwdCtl | c.ctl&srcMask
+	for i := 0; c.ctl&busy != 0; i++ {
+		if i == 1000 {
+			return errors.New("clock is stuck busy")
+		}
+		time.Sleep(time.Microsecond)
+	}
+	c.div = passwdDiv | div
+	c.ctl = passwdCtl | src
+	c.ctl = passwdCtl | src | enabClk
+	return nil
+}
+
+// clockMap is the memory mapped clock registers.
+//
+// The clock #1 must not be touched since it is being used by the ethernet
+// controller.
+//
+// Page 107 for gp0~gp2.
+// https://scribd.com/doc/127599939/BCM2835-Audio-clocks for PCM/PWM.
+type clockMap struct {
+	reserved0 [0x70 / 4]uint32 //
+	gp0       clock            // CM_GP0CTL+CM_GP0DIV; 0x70-0x74
+	gp1       clock            // CM_GP1CTL+CM_GP1DIV; 0x78-0x7C
+	gp2       clock            // CM_GP2CTL+CM_GP2DIV; 0x80-0x84
+	reserved1 [(0x98 - 0x88) / 4]uint32
+	pcm       clock // CM_PCMCTL+CM_PCMDIV; 0x98-0x9C
+	pwm       clock // CM_PWMCTL+CM_PWMDIV; 0xA0-0xA4
+}
+
+var clockMemory *clockMap
@@ -31,6 +31,19 @@
 
 package bcm283x
 
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/host/pmem"
+	"periph.io/x/periph/host/videocore"
+)
+
 // Pages 47-50
 type dmaStatus uint32
 
@@ -66,45 +79,8 @@ const (
 	// 25:21 Slows down the DMA throughput by setting the numbre of dummy cycles
 	// burnt after each DMA read or write is completed.
 	waitCyclesShift = 21 // WAITS
-	// 20:16 Peripheral mapping (1-31) whose ready signal shall be used to
-	// control the rate of the transfers. 0 means continuous un-paced transfer.
-	//
-	// It is the source used to pace the data reads and writes operations, each
-	// pace being a DReq (Data Request).
-	//
-	// Page 61
-	fire          dmaTransferInfo = iota << 16 // PERMAP; Continuous trigger
-	dsi                                        //
-	pcmTX                                      //
-	pcmRX                                      //
-	smi                                        //
-	pwm                                        //
-	spiTX                                      //
-	spiRX                                      //
-	bscSPIslaveTX                              //
-	bscSPIslaveRX                              //
-	unused                                     //
-	eMMC                                       //
-	uartTX                                     //
-	sdHost                                     //
-	uartRX                                     //
-	dsi2                                       // Same as dsi
-	slimBusMCTX                                //
-	hdmi                                       //
-	slimBusMCRX                                //
-	slimBusDC0                                 //
-	slimBusDC1                                 //
-	slimBusDC2                                 //
-	slimBusDC3                                 //
-	slimBusDC4                                 //
-	scalerFifo0                                // Also on SMI; SMI can be disabled with smiDisable
-	scalerFifo1                                //
-	scalerFifo2                                //
-	slimBusDC5                                 //
-	slimBusDC6                                 //
-	slimBusDC7                                 //
-	slimBusDC8                                 //
-	slimBusDC9                                 //
+	// 20:16 Peripheral mapping (1-31); see fire and the following constants.
+	permapShift = 16 // PERMAP
 
 	burstLengthShift                 = 12      // BURST_LENGTH 15:12 0 means a single transfer.
 	srcIgnore        dmaTransferInfo = 1 << 11 // SRC_IGNORE Source won't be read, output will be zeros.
@@ -122,6 +98,48 @@ const (
 	interruptEnable dmaTransferInfo = 1 << 0 // INTEN Generate an interrupt upon completion.
 )
 
+// Peripheral mapping (PERMAP) whose ready signal shall be used to control the
+// rate of the transfers. 0 means continuous un-paced transfer.
+//
+// It is the source used to pace the data reads and writes operations, each
+// pace being a DReq (Data Request).
+//
+// Page 61
+const (
+	fire          dmaTransferInfo = iota << permapShift // Continuous trigger
+	dsi                                                 //
+	pcmTX                                               //
+	pcmRX                                               //
+	smi                                                 //
+	pwm                                                 //
+	spiTX                                               //
+	spiRX                                               //
+	bscSPIslaveTX                                       //
+	bscSPIslaveRX                                       //
+	unused                                              //
+	eMMC                                                //
+	uartTX                                              //
+	sdHost                                              //
+	uartRX                                              //
+	dsi2                                                // Same as dsi
+	slimBusMCTX                                         //
+	hdmi                                                //
+	slimBusMCRX                                         //
+	slimBusDC0                                          //
+	slimBusDC1                                          //
+	slimBusDC2                                          //
+	slimBusDC3                                          //
+	slimBusDC4                                          //
+	scalerFifo0                                         // Also on SMI; SMI can be disabled with smiDisable
+	scalerFifo1                                         //
+	scalerFifo2                                         //
+	slimBusDC5                                          //
+	slimBusDC6                                          //
+	slimBusDC7                                          //
+	slimBusDC8                                          //
+	slimBusDC9                                          //
+)
+
 // Page 55
 type dmaDebug uint32
 
@@ -150,3 +168,244 @@ type dmaTransferLen uint32
 // 31:16 dstStride byte increment to apply at the end of each row in 2D mode
 // 15:0  srcStride byte increment to apply at the end of each row in 2D mode
 type dmaStride uint32
+
+// controlBlock is a DMA control block; it describes one transfer.
+//
+// It must be aligned on 256 bits.
+//
+// Page 40.
+type controlBlock struct {
+	transferInfo dmaTransferInfo // TI
+	srcAddr      uint32          // SOURCE_AD Bus address
+	dstAddr      uint32          // DEST_AD Bus address
+	txLen        dmaTransferLen  // TXFR_LEN
+	stride       dmaStride       // STRIDE
+	nextCB       uint32          // NEXTCONBK Bus address of the next control block; 0 to stop.
+	reserved     [2]uint32       //
+}
+
+// dmaChannel is the memory mapped registers of one DMA channel.
+//
+// Pages 41-54.
+type dmaChannel struct {
+	cs           dmaStatus       // CS
+	cbAddr       uint32          // CONBLK_AD Bus address of the current control block.
+	transferInfo dmaTransferInfo // TI Read only copy of the current control block.
+	srcAddr      uint32          // SOURCE_AD
+	dstAddr      uint32          // DEST_AD
+	txLen        dmaTransferLen  // TXFR_LEN
+	stride       dmaStride       // STRIDE
+	nextCB       uint32          // NEXTCONBK
+	debug        dmaDebug        // DEBUG
+	reserved     [55]uint32      // Pad to 0x100
+}
+
+// dmaMap is the memory mapped DMA controller for channels 0 to 14. Channel 15
+// is at a completely different address.
+//
+// Page 40.
+type dmaMap struct {
+	channels  [15]dmaChannel              // 0x000-0xEFF
+	reserved0 [(0xFE0 - 0xF00) / 4]uint32 //
+	intStatus uint32                      // INT_STATUS 0xFE0
+	reserved1 [3]uint32                   //
+	enable    uint32                      // ENABLE 0xFF0
+}
+
+var dmaMemory *dmaMap
+
+// Bus addresses as seen by the DMA controller. They are different from the
+// physical addresses seen by the CPU.
+//
+// Page 6.
+const (
+	busPeripherals = 0x7E000000
+	busUncached    = 0xC0000000
+	// GPSET0 to GPCLR1.
+	busGPIOSetClear = busPeripherals + 0x200000 + 0x1C
+	// FIF1.
+	busPWMFIFO = busPeripherals + 0x20C000 + 0x18
+)
+
+// PWM via DMA.
+//
+// The DMA controller runs a circular chain of control blocks. Each step of the
+// chain writes to the GPIO set and clear registers, then writes a dummy word
+// into the PWM FIFO. The FIFO is consumed at a fixed rate by the PWM generator
+// channel 1, which paces the chain via the DREQ signal.
+//
+// Each pin is set at the first step and cleared at the step matching its duty
+// cycle. Changing the duty cycle only modifies the data words, the chain
+// itself is never modified.
+const (
+	// dmaPWMChannel is the DMA channel used. It is the one used by pi-blaster.
+	dmaPWMChannel = 14
+	// dmaPWMStep is the time taken by each step.
+	dmaPWMStep = 10 * time.Microsecond
+	// dmaPWMSteps is the number of steps in a period.
+	dmaPWMSteps = 2000
+	// dmaPWMPeriod is the period of the signal on all the pins; 50Hz so it is
+	// usable for servos.
+	dmaPWMPeriod = dmaPWMStep * dmaPWMSteps
+)
+
+// dmaPWMStepBlock is the memory used by one step.
+//
+// It is 96 bytes so the control blocks are aligned on 256 bits.
+type dmaPWMStepBlock struct {
+	// gpio writes data[0:5] to GPSET0, GPSET1, reserved, GPCLR0, GPCLR1.
+	gpio controlBlock
+	// delay writes data[5] to the PWM FIFO.
+	delay controlBlock
+	data  [8]uint32
+}
+
+// dmaPWM drives the pins via DMA.
+type dmaPWM struct {
+	mu    sync.Mutex
+	buf   pmem.Mem
+	steps *[dmaPWMSteps]dmaPWMStepBlock
+	clear [54]int // Step at which each pin is cleared, -1 if not cleared.
+}
+
+// set outputs a PWM signal with the specified duty on the pin, starting the
+// DMA controller as needed.
+func (d *dmaPWM) set(number int, duty int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.buf == nil {
+		if dmaMemory == nil || pwmMemory == nil || clockMemory == nil {
+			return errors.New("dma pwm requires access to /dev/mem, try as root")
+		}
+		buf, err := videocore.Alloc((int(unsafe.Sizeof(*d.steps)) + 0xFFF) &^ 0xFFF)
+		if err != nil {
+			return err
+		}
+		if err := d.start(buf); err != nil {
+			buf.Close()
+			return err
+		}
+	}
+	// Setting at step 0 and clearing at step 0 means low; setting at step 0
+	// and never clearing means high.
+	step := (duty*dmaPWMSteps + gpio.Half) / gpio.Max
+	bank, bit := number/32, uint32(1)<<uint(number%32)
+	if step != 0 {
+		d.steps[0].data[bank] |= bit
+	}
+	if step != dmaPWMSteps {
+		d.steps[step].data[3+bank] |= bit
+	}
+	// Remove the previous clear step last so there is no glitch.
+	if old := d.clear[number]; old != -1 && old != step {
+		d.steps[old].data[3+bank] &^= bit
+	}
+	if step == 0 {
+		d.steps[0].data[bank] &^= bit
+	}
+	if step == dmaPWMSteps {
+		step = -1
+	}
+	d.clear[number] = step
+	return nil
+}
+
+// running returns true if the DMA controller was started.
+func (d *dmaPWM) running() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.buf != nil
+}
+
+// release stops driving the pin.
+func (d *dmaPWM) release(number int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.buf == nil {
+		return
+	}
+	bank, bit := number/32, uint32(1)<<uint(number%32)
+	d.steps[0].data[bank] &^= bit
+	if old := d.clear[number]; old != -1 {
+		d.steps[old].data[3+bank] &^= bit
+	}
+	d.clear[number] = -1
+}
+
+// start initializes the control blocks in buf and starts the DMA controller
+// and the PWM generator channel 1 to pace it.
+func (d *dmaPWM) start(buf pmem.Mem) error {
+	if pwmMemory.ctl&pwen1 != 0 && pwmMemory.ctl&usef1 == 0 {
+		return errors.New("dma pwm can't be used while PWM0_OUT is used")
+	}
+	if err := buf.Struct(reflect.ValueOf(&d.steps)); err != nil {
+		return err
+	}
+	base := uint32(buf.PhysAddr()) | busUncached
+	size := uint32(unsafe.Sizeof(dmaPWMStepBlock{}))
+	for i := range d.steps {
+		s := &d.steps[i]
+		addr := base + uint32(i)*size
+		next := base + uint32((i+1)%dmaPWMSteps)*size
+		s.data = [8]uint32{}
+		s.gpio = controlBlock{
+			transferInfo: waitResp | srcInc | dstInc,
+			srcAddr:      addr + uint32(unsafe.Offsetof(s.data)),
+			dstAddr:      busGPIOSetClear,
+			txLen:        5 * 4,
+			nextCB:       addr + uint32(unsafe.Offsetof(s.delay)),
+		}
+		s.delay = controlBlock{
+			transferInfo: waitResp | dstDReq | pwm,
+			srcAddr:      addr + uint32(unsafe.Offsetof(s.data)) + 5*4,
+			dstAddr:      busPWMFIFO,
+			txLen:        4,
+			nextCB:       next,
+		}
+	}
+	for i := range d.clear {
+		d.clear[i] = -1
+	}
+
+	// Pace via the PWM FIFO; one word is consumed per step.
+	if err := clockMemory.pwm.set(srcOscillator, pwmClockDiv); err != nil {
+		return err
+	}
+	pwmMemory.ctl = pwmMemory.ctl&^pwm1Mask | clrf
+	pwmMemory.rng1 = uint32(uint64(dmaPWMStep) * pwmClockFreq / uint64(time.Second))
+	pwmMemory.dmaCfg = enab | 7<<8 | 7
+	pwmMemory.ctl = pwmMemory.ctl&^(pwm1Mask|clrf) | usef1 | pwen1
+
+	c := &dmaMemory.channels[dmaPWMChannel]
+	c.cs = reset
+	time.Sleep(10 * time.Microsecond)
+	c.cs = interrupt | end
+	c.debug = readError | fifoError | readLastNotSetError
+	c.cbAddr = base
+	dmaMemory.enable |= 1 << dmaPWMChannel
+	c.cs = waitForOutstandingWrites | 8<<panicPriorityShift | 8<<priorityShift | active
+	d.buf = buf
+	return nil
+}
+
+// stop stops the DMA controller and the PWM generator channel 1, then frees
+// the memory.
+func (d *dmaPWM) stop() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.buf == nil {
+		return nil
+	}
+	dmaMemory.channels[dmaPWMChannel].cs = reset
+	pwmMemory.dmaCfg = 0
+	pwmMemory.ctl &^= pwm1Mask
+	err := d.buf.Close()
+	d.buf = nil
+	d.steps = nil
+	if err != nil {
+		return fmt.Errorf("dma pwm: %v", err)
+	}
+	return nil
+}
+
+var dmaPWMEngine dmaPWM
@@ -0,0 +1,158 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package bcm283x
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/host/pmem"
+)
+
+func TestDMAPWM(t *testing.T) {
+	defer setFakeMemory()()
+	dmaMemory = &dmaMap{}
+	buf := &fakeMem{Slice: make(pmem.Slice, unsafe.Sizeof([dmaPWMSteps]dmaPWMStepBlock{})), phys: 0x1000}
+	if err := dmaPWMEngine.start(buf); err != nil {
+		t.Fatal(err)
+	}
+	defer dmaPWMEngine.stop()
+
+	// The chain of control blocks.
+	steps := dmaPWMEngine.steps
+	if c := steps[0].gpio; c.srcAddr != 0xC0001040 || c.dstAddr != 0x7E20001C || c.txLen != 20 || c.nextCB != 0xC0001020 {
+		t.Fatalf("unexpected gpio control block %#v", c)
+	}
+	if c := steps[0].delay; c.srcAddr != 0xC0001054 || c.dstAddr != 0x7E20C018 || c.transferInfo&dstDReq == 0 || c.nextCB != 0xC0001060 {
+		t.Fatalf("unexpected delay control block %#v", c)
+	}
+	if c := steps[dmaPWMSteps-1].delay; c.nextCB != 0xC0001000 {
+		t.Fatalf("the chain must loop %#v", c)
+	}
+	// Pacing.
+	if pwmMemory.ctl&(usef1|pwen1) != usef1|pwen1 || pwmMemory.rng1 != 96 || pwmMemory.dmaCfg&enab == 0 {
+		t.Fatalf("unexpected pacing ctl 0x%x rng1 %d dmac 0x%x", pwmMemory.ctl, pwmMemory.rng1, pwmMemory.dmaCfg)
+	}
+	if c := dmaMemory.channels[dmaPWMChannel]; c.cbAddr != 0xC0001000 || c.cs&active == 0 {
+		t.Fatalf("unexpected channel 0x%x 0x%x", c.cbAddr, c.cs)
+	}
+
+	// GPIO4 is set at step 0 and cleared at step 1000.
+	if err := GPIO4.PWM(gpio.Half); err != nil {
+		t.Fatal(err)
+	}
+	if GPIO4.function() != out {
+		t.Fatal("expected out")
+	}
+	if steps[0].data[0] != 1<<4 || steps[1000].data[3] != 1<<4 {
+		t.Fatalf("unexpected set 0x%x clear 0x%x", steps[0].data[0], steps[1000].data[3])
+	}
+	// GPIO12 is routed via DMA since PWM0 is used for pacing.
+	if err := GPIO12.PWM(gpio.Max); err != nil {
+		t.Fatal(err)
+	}
+	if steps[0].data[0] != 1<<4|1<<12 {
+		t.Fatalf("unexpected set 0x%x", steps[0].data[0])
+	}
+	// GPIO45 uses PWM1.
+	if err := GPIO45.PWM(gpio.Half); err != nil {
+		t.Fatal(err)
+	}
+	if GPIO45.usingDMA || pwmMemory.ctl&(msen2|pwen2) != msen2|pwen2 {
+		t.Fatal("GPIO45 should use the hardware PWM")
+	}
+
+	// Changing the duty moves the clear step.
+	if err := GPIO4.PWM(gpio.Max / 4); err != nil {
+		t.Fatal(err)
+	}
+	if steps[1000].data[3] != 0 || steps[500].data[3] != 1<<4 {
+		t.Fatal("clear step didn't move")
+	}
+	if err := GPIO4.PWM(0); err != nil {
+		t.Fatal(err)
+	}
+	if steps[0].data[0] != 1<<12 || steps[0].data[3] != 1<<4 || steps[500].data[3] != 0 {
+		t.Fatal("duty 0 must clear at step 0")
+	}
+	if _, err := GPIO4.PWMPeriod(gpio.Half, time.Millisecond); err == nil {
+		t.Fatal("only 20ms is supported")
+	}
+	if d, err := GPIO4.PWMPeriod(gpio.Half, 20*time.Millisecond); err != nil || d != 20*time.Millisecond {
+		t.Fatal(d, err)
+	}
+
+	// Out() stops the DMA from driving the pin.
+	if err := GPIO4.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if GPIO4.usingDMA || steps[0].data[0] != 1<<12 || steps[1000].data[3] != 0 {
+		t.Fatal("GPIO4 must be released")
+	}
+	if err := GPIO12.In(gpio.PullNoChange, gpio.NoEdge); err != nil {
+		t.Fatal(err)
+	}
+	if steps[0].data[0] != 0 {
+		t.Fatal("GPIO12 must be released")
+	}
+
+	if err := dmaPWMEngine.stop(); err != nil {
+		t.Fatal(err)
+	}
+	if dmaPWMEngine.running() || dmaMemory.channels[dmaPWMChannel].cs != reset || pwmMemory.ctl&pwm1Mask != 0 {
+		t.Fatal("expected stopped")
+	}
+}
+
+func TestDMAPWM_busy(t *testing.T) {
+	defer setFakeMemory()()
+	dmaMemory = &dmaMap{}
+	if err := GPIO12.PWM(gpio.Half); err != nil {
+		t.Fatal(err)
+	}
+	buf := &fakeMem{Slice: make(pmem.Slice, unsafe.Sizeof([dmaPWMSteps]dmaPWMStepBlock{}))}
+	if err := dmaPWMEngine.start(buf); err == nil {
+		t.Fatal("PWM0 is in use")
+	}
+}
+
+func TestDMAStructSizes(t *testing.T) {
+	if s := unsafe.Sizeof(controlBlock{}); s != 32 {
+		t.Fatal(s)
+	}
+	if s := unsafe.Sizeof(dmaChannel{}); s != 0x100 {
+		t.Fatal(s)
+	}
+	if s := unsafe.Offsetof(dmaMap{}.enable); s != 0xFF0 {
+		t.Fatal(s)
+	}
+	if s := unsafe.Sizeof(dmaPWMStepBlock{}); s%32 != 0 {
+		t.Fatal(s)
+	}
+	if s := unsafe.Offsetof(clockMap{}.pwm); s != 0xA0 {
+		t.Fatal(s)
+	}
+	if s := unsafe.Offsetof(pwmMap{}.dat2); s != 0x24 {
+		t.Fatal(s)
+	}
+}
+
+//
+
+// fakeMem implements pmem.Mem on normal memory.
+type fakeMem struct {
+	pmem.Slice
+	phys uint64
+}
+
+func (f *fakeMem) Close() error {
+	return nil
+}
+
+func (f *fakeMem) PhysAddr() uint64 {
+	return f.phys
+}
@@ -18,6 +18,7 @@ import (
 	"periph.io/x/periph"
 	"periph.io/x/periph/conn/gpio"
 	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/pin"
 	"periph.io/x/periph/host/distro"
 	"periph.io/x/periph/host/pmem"
 	"periph.io/x/periph/host/sysfs"
@@ -97,6 +98,7 @@ type Pin struct {
 	// Mutable.
 	edge      *sysfs.Pin // Set once, then never set back to nil.
 	usingEdge bool       // Set when edge detection is enabled.
+	usingDMA  bool       // Set when the pin is driven by DMA for PWM.
 }
 
 // PinIO implementation.
@@ -158,6 +160,32 @@ func (p *Pin) Function() string {
 	}
 }
 
+// SetFunction changes the pin function to f, as returned by Function();
+// implements pin.PinFunc.
+//
+// For "In/..." the pin is set as an input without changing its pull and for
+// "Out/..." it is set as an output at the specified level.
+func (p *Pin) SetFunction(f string) error {
+	if gpioMemory == nil {
+		return p.wrap(errors.New("subsystem not initialized"))
+	}
+	switch {
+	case strings.HasPrefix(f, "In/"):
+		return p.In(gpio.PullNoChange, gpio.NoEdge)
+	case strings.HasPrefix(f, "Out/"):
+		return p.Out(f == "Out/High")
+	}
+	alts := [...]function{alt0, alt1, alt2, alt3, alt4, alt5}
+	for i, name := range mapping[p.number] {
+		if (name != "" && f == name) || f == fmt.Sprintf("<Alt%d>", i) {
+			p.releaseDMA()
+			p.setFunction(alts[i])
+			return nil
+		}
+	}
+	return p.wrap(fmt.Errorf("unknown function %q", f))
+}
+
 // In setups a pin as an input and implements gpio.PinIn.
 //
 // Specifying a value for pull other than gpio.PullNoChange causes this
@@ -183,6 +211,7 @@ func (p *Pin) In(pull gpio.Pull, edge gpio.Edge) error {
 	if gpioMemory == nil {
 		return p.wrap(errors.New("subsystem not initialized"))
 	}
+	p.releaseDMA()
 	p.setFunction(in)
 	if pull != gpio.PullNoChange {
 		// Changing pull resistor requires a specific dance as described at
@@ -269,6 +298,7 @@ func (p *Pin) Out(l gpio.Level) error {
 		}
 		p.usingEdge = false
 	}
+	p.releaseDMA()
 	// Change output before changing mode to not create any glitch.
 	offset := p.number / 32
 	if l == gpio.Low {
@@ -280,9 +310,75 @@ func (p *Pin) Out(l gpio.Level) error {
 	return nil
 }
 
-// PWM implements gpio.PinOut.
+// PWM outputs a periodic signal on any pin and implements gpio.PinOut.
+//
+// GPIO12, GPIO13, GPIO18, GPIO19, GPIO40, GPIO41 and GPIO45 use the hardware
+// PWM generator. GPIO12, GPIO18 and GPIO40 share the PWM0 channel and GPIO13,
+// GPIO19, GPIO41 and GPIO45 share the PWM1 channel, so pins on the same channel
+// output the same signal. The PWM generator is clocked at 9.6MHz from the
+// 19.2MHz oscillator and uses M/S mode with a period of 1024 cycles, leading
+// to a ~9.4kHz output.
+//
+// All the other pins are driven by the DMA controller at 50Hz with a
+// resolution of 10µs. The DMA controller is paced by the PWM0 channel, so
+// once a pin is driven via DMA, the PWM0 pins are also driven via DMA.
+// Otherwise, DMA can't be used while a PWM0 pin is in use.
+//
+// It requires access to /dev/mem, which normally requires running as root.
 func (p *Pin) PWM(duty int) error {
-	return p.wrap(errors.New("pwm is not supported"))
+	if p.useDMA() {
+		return p.dmaPWM(duty)
+	}
+	return p.setPWM(duty, pwmRange)
+}
+
+// PWMPeriod is like PWM but with a specific period and implements
+// gpio.PinPWM.
+//
+// For the pins using the PWM generator, the period has a resolution of
+// 1/9.6MHz, ~104ns, and can be up to ~447s. Each channel has its own period.
+//
+// The pins driven by DMA only support a period of 20ms.
+func (p *Pin) PWMPeriod(duty int, period time.Duration) (time.Duration, error) {
+	if p.useDMA() {
+		if period != dmaPWMPeriod {
+			return 0, p.wrap(fmt.Errorf("invalid period %s; only %s is supported on this pin", period, dmaPWMPeriod))
+		}
+		if err := p.dmaPWM(duty); err != nil {
+			return 0, err
+		}
+		return dmaPWMPeriod, nil
+	}
+	// Check the upper bound first so the multiplication can't overflow.
+	if period <= 0 || period > time.Duration(0xFFFFFFFF)*time.Second/pwmClockFreq {
+		return 0, p.wrap(fmt.Errorf("invalid period %s", period))
+	}
+	rng := (uint64(period)*pwmClockFreq + uint64(time.Second)/2) / uint64(time.Second)
+	if rng < 2 || rng > 0xFFFFFFFF {
+		return 0, p.wrap(fmt.Errorf("invalid period %s", period))
+	}
+	if err := p.setPWM(duty, uint32(rng)); err != nil {
+		return 0, err
+	}
+	return time.Duration(rng * uint64(time.Second) / pwmClockFreq), nil
+}
+
+// Group returns a gpio.Group that reads and writes all the pins at once and
+// implements gpio.PinGrouper.
+//
+// Returns nil if the pins are not all bcm283x pins in the same bank, either
+// GPIO0~GPIO31 or GPIO32~GPIO46.
+func (p *Pin) Group(pins []gpio.PinIO) gpio.Group {
+	g := &group{pins: pins, real: make([]*Pin, len(pins)), bits: make([]uint32, len(pins)), bank: p.number / 32}
+	for i, pin := range pins {
+		r, ok := pin.(*Pin)
+		if !ok || r.number/32 != g.bank {
+			return nil
+		}
+		g.real[i] = r
+		g.bits[i] = 1 << uint(r.number%32)
+	}
+	return g
 }
 
 // Special functionality.
@@ -311,6 +407,167 @@ func (p *Pin) setFunction(f function) {
 	gpioMemory.functionSelect[off] = (gpioMemory.functionSelect[off] &^ (7 << shift)) | (uint32(f) << shift)
 }
 
+// group implements gpio.Group for pins in the same bank.
+type group struct {
+	pins []gpio.PinIO
+	real []*Pin
+	bits []uint32 // Bit of each pin in the bank registers.
+	bank int
+}
+
+func (g *group) String() string {
+	names := make([]string, len(g.pins))
+	for i, p := range g.pins {
+		names[i] = p.String()
+	}
+	return "Group(" + strings.Join(names, ", ") + ")"
+}
+
+func (g *group) Pins() []gpio.PinIO {
+	return g.pins
+}
+
+func (g *group) In(pull gpio.Pull) error {
+	for _, p := range g.real {
+		if err := p.In(pull, gpio.NoEdge); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read reads GPLEVn once.
+func (g *group) Read() uint64 {
+	if gpioMemory == nil {
+		return 0
+	}
+	l := gpioMemory.level[g.bank]
+	var out uint64
+	for i, b := range g.bits {
+		if l&b != 0 {
+			out |= 1 << uint(i)
+		}
+	}
+	return out
+}
+
+// Out writes GPSETn and GPCLRn once each.
+func (g *group) Out(value, mask uint64) error {
+	if gpioMemory == nil {
+		return errors.New("bcm283x-gpio: subsystem not initialized")
+	}
+	var set, clr uint32
+	for i, b := range g.bits {
+		if mask&(1<<uint(i)) == 0 {
+			continue
+		}
+		if value&(1<<uint(i)) != 0 {
+			set |= b
+		} else {
+			clr |= b
+		}
+	}
+	if set != 0 {
+		gpioMemory.outputSet[g.bank] = set
+	}
+	if clr != 0 {
+		gpioMemory.outputClear[g.bank] = clr
+	}
+	// Pins that are not yet plain outputs are set up one by one. Their level
+	// was set above so there is no glitch.
+	for i, p := range g.real {
+		if mask&(1<<uint(i)) != 0 && (p.usingEdge || p.usingDMA || p.function() != out) {
+			if err := p.Out(value&(1<<uint(i)) != 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pwmFunction returns the PWM channel a pin can be connected to and the
+// alternate function to select to do so.
+//
+// Returns -1 if the pin can't be used for PWM.
+func pwmFunction(number int) (int, function) {
+	switch number {
+	case 12, 40:
+		return 0, alt0
+	case 18:
+		return 0, alt5
+	case 13, 41, 45:
+		return 1, alt0
+	case 19:
+		return 1, alt5
+	default:
+		return -1, in
+	}
+}
+
+// setPWM outputs a PWM signal with a period of rng PWM clock cycles with the
+// PWM generator.
+func (p *Pin) setPWM(duty int, rng uint32) error {
+	channel, f := pwmFunction(p.number)
+	if err := p.preparePWM(duty); err != nil {
+		return err
+	}
+	if pwmMemory == nil || clockMemory == nil {
+		return p.wrap(errors.New("pwm requires access to /dev/mem, try as root"))
+	}
+	p.releaseDMA()
+	if err := clockMemory.pwm.set(srcOscillator, pwmClockDiv); err != nil {
+		return p.wrap(err)
+	}
+	pwmMemory.set(channel, rng, uint32(uint64(duty)*uint64(rng)/gpio.Max))
+	p.setFunction(f)
+	return nil
+}
+
+// dmaPWM outputs a PWM signal via DMA.
+func (p *Pin) dmaPWM(duty int) error {
+	if err := p.preparePWM(duty); err != nil {
+		return err
+	}
+	if err := dmaPWMEngine.set(p.number, duty); err != nil {
+		return p.wrap(err)
+	}
+	p.usingDMA = true
+	p.setFunction(out)
+	return nil
+}
+
+// preparePWM validates the duty and disables edge detection.
+func (p *Pin) preparePWM(duty int) error {
+	if duty < 0 || duty > gpio.Max {
+		return p.wrap(fmt.Errorf("invalid duty %d", duty))
+	}
+	if gpioMemory == nil {
+		return p.wrap(errors.New("subsystem not initialized"))
+	}
+	if p.usingEdge {
+		// First disable edges.
+		if err := p.edge.In(gpio.PullNoChange, gpio.NoEdge); err != nil {
+			return p.wrap(err)
+		}
+		p.usingEdge = false
+	}
+	return nil
+}
+
+// useDMA returns true if the pin must be driven via DMA for PWM.
+func (p *Pin) useDMA() bool {
+	channel, _ := pwmFunction(p.number)
+	return channel == -1 || (channel == 0 && dmaPWMEngine.running())
+}
+
+// releaseDMA stops driving the pin via DMA.
+func (p *Pin) releaseDMA() {
+	if p.usingDMA {
+		dmaPWMEngine.release(p.number)
+		p.usingDMA = false
+	}
+}
+
 func (p *Pin) wrap(err error) error {
 	return fmt.Errorf("bcm283x-gpio (%s): %v", p, err)
 }
@@ -602,6 +859,8 @@ func getBaseAddress() uint64 {
 
 // driverGPIO implements periph.Driver.
 type driverGPIO struct {
+	// views are the memory mappings done in Init() to unmap in Close().
+	views []*pmem.View
 }
 
 func (d *driverGPIO) String() string {
@@ -635,10 +894,25 @@ func (d *driverGPIO) Init() (bool, error) {
 			}
 			return true, err
 		}
+		// Only keep this one, the /dev/gpiomem mapping is shared.
+		d.views = append(d.views, m)
 	}
 	if err := m.Struct(reflect.ValueOf(&gpioMemory)); err != nil {
 		return true, err
 	}
+	// The PWM generator, the clock manager and the DMA controller are not
+	// exposed by /dev/gpiomem so they can only be mapped via /dev/mem. This is
+	// not fatal, only PWM is unavailable.
+	base := getBaseAddress() - 0x200000
+	if pwmMemory == nil {
+		_ = d.mapStruct(base+0x20C000, reflect.ValueOf(&pwmMemory))
+	}
+	if clockMemory == nil {
+		_ = d.mapStruct(base+0x101000, reflect.ValueOf(&clockMemory))
+	}
+	if dmaMemory == nil {
+		_ = d.mapStruct(base+0x7000, reflect.ValueOf(&dmaMemory))
+	}
 
 	functions := map[string]struct{}{}
 	for i := range cpuPins {
@@ -664,12 +938,50 @@ func (d *driverGPIO) Init() (bool, error) {
 	return true, nil
 }
 
+// Close stops the DMA driven PWM and unmaps the registers.
+func (d *driverGPIO) Close() error {
+	err := dmaPWMEngine.stop()
+	for i := range cpuPins {
+		cpuPins[i].usingDMA = false
+	}
+	for _, v := range d.views {
+		if err2 := v.Close(); err == nil {
+			err = err2
+		}
+	}
+	d.views = nil
+	gpioMemory = nil
+	pwmMemory = nil
+	clockMemory = nil
+	dmaMemory = nil
+	return err
+}
+
+// mapStruct is like pmem.MapStruct() but keeps the mapping to unmap it in
+// Close().
+func (d *driverGPIO) mapStruct(base uint64, v reflect.Value) error {
+	m, err := pmem.Map(base, int(v.Elem().Type().Elem().Size()))
+	if err != nil {
+		return err
+	}
+	if err := m.Struct(v); err != nil {
+		m.Close()
+		return err
+	}
+	d.views = append(d.views, m)
+	return nil
+}
+
 func init() {
 	if isArm {
 		periph.MustRegister(&driverGPIO{})
 	}
 }
 
+var _ periph.DriverCloser = &driverGPIO{}
 var _ gpio.PinIn = &Pin{}
 var _ gpio.PinOut = &Pin{}
 var _ gpio.PinIO = &Pin{}
+var _ gpio.PinPWM = &Pin{}
+var _ gpio.PinGrouper = &Pin{}
+var _ pin.PinFunc = &Pin{}
@@ -0,0 +1,166 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package bcm283x
+
+import (
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+func TestPin_PWM(t *testing.T) {
+	defer setFakeMemory()()
+	if err := GPIO18.PWM(gpio.Half); err != nil {
+		t.Fatal(err)
+	}
+	if f := GPIO18.function(); f != alt5 {
+		t.Fatalf("expected alt5, got %d", f)
+	}
+	if s := GPIO18.Function(); s != "PWM0_OUT" {
+		t.Fatal(s)
+	}
+	if c := clockMemory.pwm; c.ctl != passwdCtl|srcOscillator|enabClk || c.div != passwdDiv|pwmClockDiv<<diviShift {
+		t.Fatalf("unexpected clock 0x%x 0x%x", c.ctl, c.div)
+	}
+	if pwmMemory.ctl != msen1|pwen1 {
+		t.Fatalf("unexpected ctl 0x%x", pwmMemory.ctl)
+	}
+	if pwmMemory.rng1 != pwmRange || pwmMemory.dat1 != pwmRange/2 {
+		t.Fatalf("unexpected rng1 %d dat1 %d", pwmMemory.rng1, pwmMemory.dat1)
+	}
+
+	// The second channel doesn't affect the first one.
+	if err := GPIO45.PWM(gpio.Max / 4); err != nil {
+		t.Fatal(err)
+	}
+	if f := GPIO45.function(); f != alt0 {
+		t.Fatalf("expected alt0, got %d", f)
+	}
+	if pwmMemory.ctl != msen1|pwen1|msen2|pwen2 {
+		t.Fatalf("unexpected ctl 0x%x", pwmMemory.ctl)
+	}
+	if pwmMemory.rng2 != pwmRange || pwmMemory.dat2 != pwmRange/4 || pwmMemory.dat1 != pwmRange/2 {
+		t.Fatalf("unexpected dat1 %d rng2 %d dat2 %d", pwmMemory.dat1, pwmMemory.rng2, pwmMemory.dat2)
+	}
+}
+
+func TestPin_PWMPeriod(t *testing.T) {
+	defer setFakeMemory()()
+	// A servo at 50Hz.
+	d, err := GPIO13.PWMPeriod(gpio.Max/4, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 20*time.Millisecond {
+		t.Fatal(d)
+	}
+	if pwmMemory.rng2 != 192000 || pwmMemory.dat2 != 48000 {
+		t.Fatalf("unexpected rng2 %d dat2 %d", pwmMemory.rng2, pwmMemory.dat2)
+	}
+	// Rounded to the PWM clock resolution.
+	if d, err = GPIO13.PWMPeriod(gpio.Half, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if d != 1041 {
+		t.Fatal(d)
+	}
+	if _, err := GPIO13.PWMPeriod(gpio.Half, 100); err == nil {
+		t.Fatal("period too short")
+	}
+	if _, err := GPIO13.PWMPeriod(gpio.Half, time.Hour); err == nil {
+		t.Fatal("period too long")
+	}
+	// Would wrap around to a range of 1000 if the multiplication overflowed.
+	if _, err := GPIO13.PWMPeriod(gpio.Half, 1921535945178); err == nil {
+		t.Fatal("period too long")
+	}
+}
+
+func TestPin_PWM_fail(t *testing.T) {
+	if GPIO12.PWM(gpio.Half) == nil {
+		t.Fatal("subsystem not initialized")
+	}
+	defer setFakeMemory()()
+	if GPIO4.PWM(gpio.Half) == nil {
+		t.Fatal("dma memory not mapped")
+	}
+	if GPIO12.PWM(-1) == nil {
+		t.Fatal("invalid duty")
+	}
+	if GPIO12.PWM(gpio.Max+1) == nil {
+		t.Fatal("invalid duty")
+	}
+	pwmMemory = nil
+	if GPIO12.PWM(gpio.Half) == nil {
+		t.Fatal("pwm memory not mapped")
+	}
+}
+
+func TestGroup(t *testing.T) {
+	defer setFakeMemory()()
+	if GPIO4.Group([]gpio.PinIO{GPIO4, GPIO40}) != nil {
+		t.Fatal("different banks")
+	}
+	if GPIO4.Group([]gpio.PinIO{GPIO4, gpio.INVALID}) != nil {
+		t.Fatal("different drivers")
+	}
+	g, err := gpio.NewGroup(GPIO4, GPIO5, GPIO6, GPIO27)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := g.String(); s != "Group(GPIO4, GPIO5, GPIO6, GPIO27)" {
+		t.Fatal(s)
+	}
+	// The first call sets the pins as output one by one.
+	if err := g.Out(0, 0xB); err != nil {
+		t.Fatal(err)
+	}
+	if GPIO4.function() != out || GPIO5.function() != out || GPIO6.function() != in || GPIO27.function() != out {
+		t.Fatal("expected pins as output")
+	}
+	gpioMemory.outputClear[0] = 0
+	if err := g.Out(0x9, 0xB); err != nil {
+		t.Fatal(err)
+	}
+	if gpioMemory.outputSet[0] != 1<<4|1<<27 || gpioMemory.outputClear[0] != 1<<5 {
+		t.Fatalf("unexpected set 0x%x clear 0x%x", gpioMemory.outputSet[0], gpioMemory.outputClear[0])
+	}
+	gpioMemory.level[0] = 1<<6 | 1<<27
+	if v := g.Read(); v != 0xC {
+		t.Fatal(v)
+	}
+}
+
+func TestClock_set(t *testing.T) {
+	c := clock{ctl: srcPLLD | enabClk, div: 10 << diviShift}
+	if err := c.set(srcOscillator, 0); err == nil {
+		t.Fatal("invalid divisor")
+	}
+	if err := c.set(srcOscillator, 3); err != nil {
+		t.Fatal(err)
+	}
+	if c.ctl != passwdCtl|srcOscillator|enabClk || c.div != passwdDiv|3<<diviShift {
+		t.Fatalf("unexpected clock 0x%x 0x%x", c.ctl, c.div)
+	}
+	c.ctl |= busy
+	if err := c.set(srcOscillator, 3); err != nil {
+		t.Fatal("same configuration must be a no-op")
+	}
+}
+
+//
+
+// setFakeMemory replaces the memory mapped registers with fake ones and
+// returns a function to restore them.
+func setFakeMemory() func() {
+	oldGPIO, oldPWM, oldClock, oldDMA := gpioMemory, pwmMemory, clockMemory, dmaMemory
+	gpioMemory = &gpioMap{}
+	pwmMemory = &pwmMap{}
+	clockMemory = &clockMap{}
+	return func() {
+		gpioMemory, pwmMemory, clockMemory, dmaMemory = oldGPIO, oldPWM, oldClock, oldDMA
+	}
+}
@@ -121,3 +121,50 @@ const (
 
 // Page 145.
 type pwmDMACfg uint32
+
+// pwmMap is the block to control the PWM generator.
+//
+// Note that pins are named PWM0 and PWM1 but the channels are named 1 and 2.
+//
+// Page 141
+type pwmMap struct {
+	ctl    pwmControl // CTL
+	status pwmStatus  // STA
+	dmaCfg pwmDMACfg  // DMAC
+	dummy1 uint32     // 0x0C
+	rng1   uint32     // RNG1
+	dat1   uint32     // DAT1
+	fifo   uint32     // FIF1
+	dummy2 uint32     // 0x1C
+	rng2   uint32     // RNG2
+	dat2   uint32     // DAT2
+}
+
+// set enables the channel in M/S mode; the output is high for dat clock
+// cycles out of every rng clock cycles.
+//
+// channel is 0 for PWM0 and 1 for PWM1.
+func (p *pwmMap) set(channel int, rng, dat uint32) {
+	if channel == 0 {
+		p.rng1 = rng
+		p.dat1 = dat
+		p.ctl = p.ctl&^pwm1Mask | msen1 | pwen1
+	} else {
+		p.rng2 = rng
+		p.dat2 = dat
+		p.ctl = p.ctl&^pwm2Mask | msen2 | pwen2
+	}
+}
+
+const (
+	// pwmClockDiv divides the 19.2MHz oscillator to clock the PWM generator at
+	// 9.6MHz.
+	pwmClockDiv = 2
+	// pwmClockFreq is the resulting PWM generator clock frequency.
+	pwmClockFreq = 19200000 / pwmClockDiv
+	// pwmRange is the default number of PWM clock cycles per period, which
+	// leads to a ~9.4kHz output.
+	pwmRange = 1024
+)
+
+var pwmMemory *pwmMap
@@ -4,7 +4,13 @@
 
 package host
 
-import "periph.io/x/periph"
+import (
+	"periph.io/x/periph"
+
+	// Make sure the simulated host driver is registered. It is only loaded
+	// when selected explicitly.
+	_ "periph.io/x/periph/host/sim"
+)
 
 // Init calls periph.Init() and returns it as-is.
 //
@@ -13,3 +19,10 @@ import "periph.io/x/periph"
 func Init() (*periph.State, error) {
 	return periph.Init()
 }
+
+// InitWithOpts calls periph.InitWithOpts() and returns it as-is.
+//
+// It is like Init() but only initializes the drivers selected by opts.
+func InitWithOpts(opts *periph.Opts) (*periph.State, error) {
+	return periph.InitWithOpts(opts)
+}
@@ -0,0 +1,30 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package sim implements a simulated host, to develop and run programs on a
+// computer without any hardware attached.
+//
+// It registers fake GPIO pins, I²C buses and SPI ports backed by gpiotest,
+// i2ctest and spitest. The layout mimics a Raspberry Pi:
+//
+//   - GPIO0 to GPIO27 in gpioreg.
+//   - I2C1 in i2creg, with SDA on GPIO2 and SCL on GPIO3.
+//   - SPI0.0 and SPI0.1 in spireg, with CLK on GPIO11, MOSI on GPIO10, MISO on
+//     GPIO9 and CS on GPIO8 and GPIO7 respectively.
+//
+// Writes on the buses are recorded in the Ops of I2C1 and SPI0 and reads
+// return zeros.
+//
+// The driver is opt-in: it is only loaded when it is explicitly selected by
+// name, either via the environment variable:
+//
+//	PERIPH_DRIVERS=sim
+//
+// or via the Init options:
+//
+//	host.InitWithOpts(&periph.Opts{Only: []string{"sim"}})
+//
+// Only selecting "sim" ensures no other host driver registers conflicting pins
+// and buses.
+package sim
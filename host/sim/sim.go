@@ -0,0 +1,234 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"fmt"
+
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/spi"
+	"periph.io/x/periph/conn/spi/spireg"
+	"periph.io/x/periph/conn/spi/spitest"
+)
+
+// Pins are the simulated GPIO pins GPIO0 to GPIO27.
+//
+// Send a level on the EdgesChan of a pin to simulate an edge.
+//
+// This global variable is initialized at driver initialization and cleared by
+// periph.Shutdown().
+var Pins []*gpiotest.Pin
+
+// I2C1 records the transactions done on the simulated I²C bus I2C1.
+//
+// Grab its Mutex before reading or resetting Ops.
+var I2C1 *i2ctest.Record
+
+// SPI0 records the transactions done on the simulated SPI ports SPI0.0 and
+// SPI0.1.
+//
+// Grab their Mutex before reading or resetting Ops.
+var SPI0 [2]*spitest.Record
+
+//
+
+// functions are the pins that are not general purpose.
+var functions = map[int]string{
+	2:  "I2C1_SDA",
+	3:  "I2C1_SCL",
+	7:  "SPI0_CS1",
+	8:  "SPI0_CS0",
+	9:  "SPI0_MISO",
+	10: "SPI0_MOSI",
+	11: "SPI0_CLK",
+}
+
+// i2cBus implements i2c.Bus. It acknowledges all transactions and reads
+// zeros.
+type i2cBus struct {
+	scl, sda gpio.PinIO
+}
+
+func (i *i2cBus) String() string {
+	return "I2C1"
+}
+
+func (i *i2cBus) Tx(addr uint16, w, r []byte) error {
+	for j := range r {
+		r[j] = 0
+	}
+	return nil
+}
+
+func (i *i2cBus) Speed(hz int64) error {
+	return nil
+}
+
+func (i *i2cBus) SCL() gpio.PinIO {
+	return i.scl
+}
+
+func (i *i2cBus) SDA() gpio.PinIO {
+	return i.sda
+}
+
+// i2cCloser implements i2c.BusCloser on top of the shared I2C1.
+type i2cCloser struct {
+	*i2ctest.Record
+}
+
+func (i *i2cCloser) String() string {
+	return "I2C1"
+}
+
+// Close is a no-op as the bus is shared.
+func (i *i2cCloser) Close() error {
+	return nil
+}
+
+// spiConn implements spi.ConnCloser. It reads zeros.
+type spiConn struct {
+	name                string
+	clk, mosi, miso, cs gpio.PinIO
+}
+
+func (s *spiConn) String() string {
+	return s.name
+}
+
+func (s *spiConn) Tx(w, r []byte) error {
+	for i := range r {
+		r[i] = 0
+	}
+	return nil
+}
+
+func (s *spiConn) Duplex() conn.Duplex {
+	return conn.Full
+}
+
+// Close is a no-op as the port is shared.
+func (s *spiConn) Close() error {
+	return nil
+}
+
+func (s *spiConn) Speed(maxHz int64) error {
+	return nil
+}
+
+func (s *spiConn) DevParams(maxHz int64, mode spi.Mode, bits int) error {
+	return nil
+}
+
+func (s *spiConn) CLK() gpio.PinOut {
+	return s.clk
+}
+
+func (s *spiConn) MOSI() gpio.PinOut {
+	return s.mosi
+}
+
+func (s *spiConn) MISO() gpio.PinIn {
+	return s.miso
+}
+
+func (s *spiConn) CS() gpio.PinOut {
+	return s.cs
+}
+
+// driver implements periph.Driver.
+type driver struct {
+}
+
+func (d *driver) String() string {
+	return "sim"
+}
+
+func (d *driver) Prerequisites() []string {
+	return nil
+}
+
+// OptIn implements periph.DriverOptIn.
+func (d *driver) OptIn() {
+}
+
+func (d *driver) Init() (bool, error) {
+	pins := make([]*gpiotest.Pin, 28)
+	for i := range pins {
+		pins[i] = &gpiotest.Pin{
+			N:         fmt.Sprintf("GPIO%d", i),
+			Num:       i,
+			Fn:        functions[i],
+			EdgesChan: make(chan gpio.Level),
+		}
+		if err := gpioreg.Register(pins[i], true); err != nil {
+			return true, err
+		}
+	}
+	for i, f := range functions {
+		if err := gpioreg.RegisterAlias(f, i); err != nil {
+			return true, err
+		}
+	}
+	Pins = pins
+
+	I2C1 = &i2ctest.Record{Bus: &i2cBus{scl: pins[3], sda: pins[2]}}
+	i2c1 := &i2cCloser{I2C1}
+	opener := func() (i2c.BusCloser, error) {
+		return i2c1, nil
+	}
+	if err := i2creg.Register("I2C1", nil, 1, opener); err != nil {
+		return true, err
+	}
+
+	for i, cs := range []int{8, 7} {
+		r := &spitest.Record{Conn: &spiConn{
+			name: fmt.Sprintf("SPI0.%d", i),
+			clk:  pins[11],
+			mosi: pins[10],
+			miso: pins[9],
+			cs:   pins[cs],
+		}}
+		SPI0[i] = r
+		opener := func() (spi.ConnCloser, error) {
+			return r, nil
+		}
+		// Only the first chip select gets the bus number, like sysfs.
+		n := -1
+		if i == 0 {
+			n = 0
+		}
+		if err := spireg.Register(fmt.Sprintf("SPI0.%d", i), nil, n, opener); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// Close forgets the simulated pins and buses.
+func (d *driver) Close() error {
+	Pins = nil
+	I2C1 = nil
+	SPI0 = [2]*spitest.Record{}
+	return nil
+}
+
+func init() {
+	periph.MustRegister(&driver{})
+}
+
+var _ periph.DriverCloser = &driver{}
+var _ periph.DriverOptIn = &driver{}
+var _ i2c.BusCloser = &i2cCloser{}
+var _ i2c.Pins = &i2cBus{}
+var _ spi.ConnCloser = &spiConn{}
+var _ spi.Pins = &spiConn{}
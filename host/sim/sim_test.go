@@ -0,0 +1,95 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"fmt"
+	"testing"
+
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/spi"
+	"periph.io/x/periph/conn/spi/spireg"
+)
+
+func TestDriver(t *testing.T) {
+	state, err := periph.InitWithOpts(&periph.Opts{Only: []string{"sim"}})
+	if err != nil || len(state.Loaded) != 1 {
+		t.Fatal(state, err)
+	}
+	defer periph.Shutdown()
+
+	p := gpioreg.ByName("I2C1_SDA")
+	if p == nil || p.(gpio.RealPin).Real() != Pins[2] {
+		t.Fatalf("unexpected pin %v", p)
+	}
+	if err := gpioreg.ByName("GPIO4").Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	if Pins[4].Read() != gpio.High {
+		t.Fatal("expected High")
+	}
+
+	b, err := i2creg.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := b.(fmt.Stringer).String(); s != "I2C1" {
+		t.Fatal(s)
+	}
+	if p := b.(i2c.Pins); p.SCL() != Pins[3] || p.SDA() != Pins[2] {
+		t.Fatal("unexpected pins")
+	}
+	r := []byte{0xFF}
+	if err := b.Tx(0x76, []byte{0xD0}, r); err != nil {
+		t.Fatal(err)
+	}
+	if r[0] != 0 || len(I2C1.Ops) != 1 || I2C1.Ops[0].Addr != 0x76 {
+		t.Fatal(r, I2C1.Ops)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := spireg.Open("SPI0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Tx([]byte{1, 2}, make([]byte, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(SPI0[1].Ops) != 1 || len(SPI0[0].Ops) != 0 {
+		t.Fatal(SPI0[1].Ops)
+	}
+	if p := c.(spi.Pins); p.CS() != Pins[7] || p.CLK() != Pins[11] {
+		t.Fatal("unexpected pins")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := periph.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if Pins != nil || gpioreg.ByName("GPIO4") != nil {
+		t.Fatal("expected the pins to be cleared")
+	}
+}
+
+func TestOptIn(t *testing.T) {
+	state, err := periph.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer periph.Shutdown()
+	for _, d := range state.Loaded {
+		if d.String() == "sim" {
+			t.Fatal("sim must not be loaded by default")
+		}
+	}
+}
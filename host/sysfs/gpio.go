@@ -5,6 +5,7 @@
 package sysfs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -40,9 +41,9 @@ type Pin struct {
 	err        error     // If open() failed
 	direction  direction // Cache of the last known direction
 	edge       gpio.Edge // Cache of the last edge used.
-	fDirection *os.File  // handle to /sys/class/gpio/gpio*/direction; never closed
-	fEdge      *os.File  // handle to /sys/class/gpio/gpio*/edge; never closed
-	fValue     *os.File  // handle to /sys/class/gpio/gpio*/value; never closed
+	fDirection *os.File  // handle to /sys/class/gpio/gpio*/direction; closed by periph.Shutdown()
+	fEdge      *os.File  // handle to /sys/class/gpio/gpio*/edge; closed by periph.Shutdown()
+	fValue     *os.File  // handle to /sys/class/gpio/gpio*/value; closed by periph.Shutdown()
 	event      event     // Initialized once
 }
 
@@ -199,6 +200,40 @@ func (p *Pin) WaitForEdge(timeout time.Duration) bool {
 	}
 }
 
+// WaitForEdgeContext waits for the next edge until ctx is done; implements
+// gpio.PinInContext.
+func (p *Pin) WaitForEdgeContext(ctx context.Context) bool {
+	ok, err := p.event.waitContext(ctx)
+	return ok && err == nil
+}
+
+// ReadEdge waits for the next edge and returns it; implements
+// gpio.PinEdgeEvents.
+//
+// gpio sysfs doesn't queue edges nor timestamp them, so edges may be
+// coalesced, the time is when the edge was noticed and the level is read
+// right after.
+func (p *Pin) ReadEdge(timeout time.Duration) (gpio.EdgeEvent, bool) {
+	if !p.WaitForEdge(timeout) {
+		return gpio.EdgeEvent{}, false
+	}
+	e := gpio.EdgeEvent{Time: monotonic(), Level: p.Read()}
+	p.mu.Lock()
+	edge := p.edge
+	p.mu.Unlock()
+	switch edge {
+	case gpio.RisingEdge, gpio.FallingEdge:
+		e.Edge = edge
+	default:
+		if e.Level == gpio.High {
+			e.Edge = gpio.RisingEdge
+		} else {
+			e.Edge = gpio.FallingEdge
+		}
+	}
+	return e, true
+}
+
 // Pull returns gpio.PullNoChange since gpio sysfs has no support for input
 // pull resistor.
 func (p *Pin) Pull() gpio.Pull {
@@ -302,6 +337,30 @@ func (p *Pin) wrap(err error) error {
 	return fmt.Errorf("sysfs-gpio (%s): %v", p, err)
 }
 
+// close closes the file handles opened by open().
+func (p *Pin) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var err error
+	if p.fEdge != nil {
+		p.event.close()
+		err = p.fEdge.Close()
+		p.fEdge = nil
+	}
+	for _, f := range []**os.File{&p.fDirection, &p.fValue} {
+		if *f != nil {
+			if err2 := (*f).Close(); err == nil {
+				err = err2
+			}
+			*f = nil
+		}
+	}
+	p.err = nil
+	p.direction = dUnknown
+	p.edge = gpio.NoEdge
+	return err
+}
+
 //
 
 var exportHandle io.Writer // handle to /sys/class/gpio/export
@@ -373,6 +432,10 @@ func (d *driverGPIO) Prerequisites() []string {
 //
 // The main drawback of GPIO sysfs is that it doesn't expose internal pull
 // resistor and it is much slower than using memory mapped hardware registers.
+//
+// When the GPIO character device is usable, the pins are still listed in Pins
+// but are not registered in gpioreg; sysfs-gpiochip registers its lines
+// instead.
 func (d *driverGPIO) Init() (bool, error) {
 	items, err := filepath.Glob("/sys/class/gpio/gpiochip*")
 	if err != nil {
@@ -385,8 +448,9 @@ func (d *driverGPIO) Init() (bool, error) {
 	// There are hosts that use non-continuous pin numbering so use a map instead
 	// of an array.
 	Pins = map[int]*Pin{}
+	register := !gpioChipUsable()
 	for _, item := range items {
-		if err := d.parseGPIOChip(item + "/"); err != nil {
+		if err := d.parseGPIOChip(item+"/", register); err != nil {
 			return true, err
 		}
 	}
@@ -397,7 +461,7 @@ func (d *driverGPIO) Init() (bool, error) {
 	return true, err
 }
 
-func (d *driverGPIO) parseGPIOChip(path string) error {
+func (d *driverGPIO) parseGPIOChip(path string, register bool) error {
 	base, err := readInt(path + "base")
 	if err != nil {
 		return err
@@ -418,6 +482,9 @@ func (d *driverGPIO) parseGPIOChip(path string) error {
 			root:   fmt.Sprintf("/sys/class/gpio/gpio%d/", i),
 		}
 		Pins[i] = p
+		if !register {
+			continue
+		}
 		if err := gpioreg.Register(p, false); err != nil {
 			return err
 		}
@@ -426,12 +493,33 @@ func (d *driverGPIO) parseGPIOChip(path string) error {
 	return nil
 }
 
+// Close closes the file handles of all the pins.
+func (d *driverGPIO) Close() error {
+	var err error
+	for _, p := range Pins {
+		if err2 := p.close(); err == nil {
+			err = err2
+		}
+	}
+	Pins = nil
+	if c, ok := exportHandle.(io.Closer); ok {
+		if err2 := c.Close(); err == nil {
+			err = err2
+		}
+	}
+	exportHandle = nil
+	return err
+}
+
 func init() {
 	if isLinux {
 		periph.MustRegister(&driverGPIO{})
 	}
 }
 
+var _ periph.DriverCloser = &driverGPIO{}
 var _ gpio.PinIn = &Pin{}
 var _ gpio.PinOut = &Pin{}
 var _ gpio.PinIO = &Pin{}
+var _ gpio.PinEdgeEvents = &Pin{}
+var _ gpio.PinInContext = &Pin{}
@@ -5,12 +5,16 @@
 package sysfs
 
 import (
+	"context"
 	"os"
 	"syscall"
+	"time"
+	"unsafe"
 )
 
 const (
 	epollET     = 1 << 31
+	epollIN     = 1
 	epollPRI    = 2
 	epollCTLAdd = 1
 	epollCTLDel = 2
@@ -21,6 +25,7 @@ type event struct {
 	event   [1]syscall.EpollEvent
 	epollFd int
 	fd      int
+	wake    [2]int // pipe used to interrupt waitContext()
 }
 
 // makeEvent creates an epoll *edge* triggered event.
@@ -53,12 +58,141 @@ func (e *event) makeEvent(f *os.File) error {
 	// outside the scope of this interface.
 	e.event[0].Events = epollPRI | epollET
 	e.event[0].Fd = int32(e.fd)
-	return syscall.EpollCtl(e.epollFd, epollCTLAdd, e.fd, &e.event[0])
+	if err := syscall.EpollCtl(e.epollFd, epollCTLAdd, e.fd, &e.event[0]); err != nil {
+		return err
+	}
+	return e.makeWake()
+}
+
+// makeEventIn creates an epoll *level* triggered event signaled when f has
+// data to be read.
+//
+// This is used for the GPIO character device, where the kernel queues each
+// edge as a record to be read. The event stays signaled until all the queued
+// records were read.
+func (e *event) makeEventIn(f *os.File) error {
+	epollFd, err := syscall.EpollCreate(1)
+	if err != nil {
+		return err
+	}
+	e.epollFd = epollFd
+	e.fd = int(f.Fd())
+	e.event[0].Events = epollIN
+	e.event[0].Fd = int32(e.fd)
+	if err = syscall.EpollCtl(e.epollFd, epollCTLAdd, e.fd, &e.event[0]); err == nil {
+		err = e.makeWake()
+	}
+	if err != nil {
+		syscall.Close(e.epollFd)
+	}
+	return err
+}
+
+// makeWake creates the pipe used to interrupt waitContext() and adds it to
+// the epoll set.
+//
+// It is created along the event, so that it is shared by concurrent
+// waitContext() calls and closed by close().
+func (e *event) makeWake() error {
+	if err := syscall.Pipe2(e.wake[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		e.wake = [2]int{}
+		return err
+	}
+	ev := syscall.EpollEvent{Events: epollIN, Fd: int32(e.wake[0])}
+	if err := syscall.EpollCtl(e.epollFd, epollCTLAdd, e.wake[0], &ev); err != nil {
+		syscall.Close(e.wake[0])
+		syscall.Close(e.wake[1])
+		e.wake = [2]int{}
+		return err
+	}
+	return nil
 }
 
 func (e *event) wait(timeoutms int) (int, error) {
 	// http://man7.org/linux/man-pages/man2/epoll_wait.2.html
-	return syscall.EpollWait(e.epollFd, e.event[:], timeoutms)
+	n, err := syscall.EpollWait(e.epollFd, e.event[:], timeoutms)
+	if n == 1 && int(e.event[0].Fd) == e.wake[0] {
+		// A concurrent waitContext() call is being cancelled; it consumes the
+		// wake up itself.
+		return 0, nil
+	}
+	return n, err
+}
+
+// waitContext waits for the event until ctx is done.
+//
+// It uses a pipe registered in the same epoll set to interrupt the wait so
+// that no polling is needed. Returns true if the event was signaled.
+func (e *event) waitContext(ctx context.Context) (bool, error) {
+	// Wait for the goroutine to exit before returning so it never writes to a
+	// stale fd. Each cancellation writes one byte and consumes it back, so that
+	// concurrent calls sharing the pipe all get woken up.
+	wake := e.wake
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	woken := false
+	defer func() {
+		close(done)
+		<-exited
+		if woken {
+			var b [1]byte
+			syscall.Read(wake[0], b[:])
+		}
+	}()
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			syscall.Write(wake[1], []byte{0})
+			woken = true
+		case <-done:
+		}
+	}()
+	var events [2]syscall.EpollEvent
+	for {
+		if err := ctx.Err(); err != nil {
+			return false, nil
+		}
+		n, err := syscall.EpollWait(e.epollFd, events[:], -1)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		signaled := false
+		for i := 0; i < n; i++ {
+			// A wake up is for this call only if ctx is done, which is checked
+			// at the top of the loop.
+			if int(events[i].Fd) != wake[0] {
+				signaled = true
+			}
+		}
+		if signaled {
+			return true, nil
+		}
+	}
+}
+
+// close closes the epoll set and the wake up pipe.
+//
+// The fds are not reset, as they may be read concurrently by lock-free
+// waiters; those get an error from the closed epoll set.
+func (e *event) close() error {
+	syscall.Close(e.wake[0])
+	syscall.Close(e.wake[1])
+	return syscall.Close(e.epollFd)
+}
+
+// monotonic returns the current time of CLOCK_MONOTONIC, the clock used by
+// the kernel to timestamp GPIO edge events.
+func monotonic() time.Duration {
+	var ts syscall.Timespec
+	// CLOCK_MONOTONIC is 1.
+	if _, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, 1, uintptr(unsafe.Pointer(&ts)), 0); errno != 0 {
+		return 0
+	}
+	return time.Duration(ts.Nano())
 }
 
 func isErrBusy(err error) bool {
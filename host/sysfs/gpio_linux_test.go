@@ -0,0 +1,74 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEvent_waitContext(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	defer r.Close()
+	var e event
+	if err := e.makeEventIn(r); err != nil {
+		t.Fatal(err)
+	}
+	defer e.close()
+	wake := e.wake
+	type result struct {
+		ok  bool
+		err error
+	}
+	// Two concurrent waiters share the wake up pipe; cancelling one doesn't
+	// affect the other.
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	c1 := make(chan result)
+	c2 := make(chan result)
+	go func() {
+		ok, err := e.waitContext(ctx1)
+		c1 <- result{ok, err}
+	}()
+	go func() {
+		ok, err := e.waitContext(ctx2)
+		c2 <- result{ok, err}
+	}()
+	cancel1()
+	select {
+	case res := <-c1:
+		if res.ok || res.err != nil {
+			t.Fatal(res)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitContext() wasn't cancelled")
+	}
+	select {
+	case res := <-c2:
+		t.Fatal("unexpected return", res)
+	case <-time.After(10 * time.Millisecond):
+	}
+	if _, err := w.Write([]byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case res := <-c2:
+		if !res.ok || res.err != nil {
+			t.Fatal(res)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitContext() wasn't signaled")
+	}
+	if e.wake != wake {
+		t.Fatal("the wake up pipe must be created once")
+	}
+}
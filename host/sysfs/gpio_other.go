@@ -7,8 +7,10 @@
 package sysfs
 
 import (
+	"context"
 	"errors"
 	"os"
+	"time"
 )
 
 type event struct{}
@@ -17,10 +19,27 @@ func (e event) makeEvent(f *os.File) error {
 	return errors.New("sysfs-gpio: unreachable code")
 }
 
+func (e event) makeEventIn(f *os.File) error {
+	return errors.New("sysfs-gpio: unreachable code")
+}
+
 func (e event) wait(timeoutms int) (int, error) {
 	return 0, errors.New("sysfs-gpio: unreachable code")
 }
 
+func (e event) waitContext(ctx context.Context) (bool, error) {
+	return false, errors.New("sysfs-gpio: unreachable code")
+}
+
+func (e event) close() error {
+	return errors.New("sysfs-gpio: unreachable code")
+}
+
+func monotonic() time.Duration {
+	// This function is not used on non-linux.
+	return 0
+}
+
 func isErrBusy(err error) bool {
 	// This function is not used on non-linux.
 	return false
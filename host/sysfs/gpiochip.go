@@ -0,0 +1,710 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpioreg"
+)
+
+// GPIOChips is all the GPIO character devices found on this host.
+//
+// This global variable is initialized at driver initialization and cleared by
+// periph.Shutdown(). Do not modify it.
+var GPIOChips []*GPIOChip
+
+// GPIOChip represents one GPIO controller as exposed by the kernel via
+// /dev/gpiochipN.
+type GPIOChip struct {
+	name  string // Something like gpiochip0
+	label string // Something like pinctrl-bcm2835
+	path  string // Something like /dev/gpiochip0
+	base  int    // Number of the first line as presented to the user
+
+	mu    sync.Mutex
+	f     *os.File // handle to /dev/gpiochipN; closed by periph.Shutdown()
+	lines []*GPIOLine
+}
+
+func (c *GPIOChip) String() string {
+	return c.name
+}
+
+// Label returns the label of the GPIO controller as reported by the kernel.
+func (c *GPIOChip) Label() string {
+	return c.label
+}
+
+// Lines returns all the lines exposed by this GPIO controller.
+func (c *GPIOChip) Lines() []*GPIOLine {
+	out := make([]*GPIOLine, len(c.lines))
+	copy(out, c.lines)
+	return out
+}
+
+// GPIOLine represents one GPIO line as found via the GPIO character device.
+//
+// It implements gpio.PinIO. The line is requested from the kernel lazily on
+// the first call to In() or Out() and is released on Close().
+type GPIOLine struct {
+	number int
+	name   string
+	chip   *GPIOChip
+	offset uint32
+
+	mu        sync.Mutex
+	f         *os.File  // handle to the line request; nil when not requested
+	direction direction // Cache of the last known direction
+	pull      gpio.Pull // Cache of the last pull used.
+	edge      gpio.Edge // Cache of the last edge used.
+	event     event     // Initialized once per line request
+}
+
+func (l *GPIOLine) String() string {
+	return l.name
+}
+
+// Name implements pins.Pin.
+func (l *GPIOLine) Name() string {
+	return l.name
+}
+
+// Number implements pins.Pin.
+func (l *GPIOLine) Number() int {
+	return l.number
+}
+
+// Offset returns the offset of the line on its GPIO controller.
+func (l *GPIOLine) Offset() int {
+	return int(l.offset)
+}
+
+// Function implements pins.Pin.
+func (l *GPIOLine) Function() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		// Not requested by this process; ask the kernel about it.
+		info, err := l.chip.lineInfo(l.offset)
+		if err != nil {
+			return "ERR"
+		}
+		if info.flags&gpioV2LineFlagOutput != 0 {
+			return "Out"
+		}
+		return "In"
+	}
+	if l.direction == dIn {
+		return "In/" + l.read().String()
+	} else if l.direction == dOut {
+		return "Out/" + l.read().String()
+	}
+	return "ERR"
+}
+
+// In setups a line as an input.
+//
+// Pull resistors are set via the line bias flags, which requires the GPIO
+// controller driver to support them.
+func (l *GPIOLine) In(pull gpio.Pull, edge gpio.Edge) error {
+	flags := uint64(gpioV2LineFlagInput)
+	switch pull {
+	case gpio.Float:
+		flags |= gpioV2LineFlagBiasDisabled
+	case gpio.PullDown:
+		flags |= gpioV2LineFlagBiasPullDown
+	case gpio.PullUp:
+		flags |= gpioV2LineFlagBiasPullUp
+	case gpio.PullNoChange:
+	default:
+		return l.wrap(fmt.Errorf("invalid pull %s", pull))
+	}
+	switch edge {
+	case gpio.NoEdge:
+	case gpio.RisingEdge:
+		flags |= gpioV2LineFlagEdgeRising
+	case gpio.FallingEdge:
+		flags |= gpioV2LineFlagEdgeFalling
+	case gpio.BothEdges:
+		flags |= gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling
+	default:
+		return l.wrap(fmt.Errorf("invalid edge %s", edge))
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.configure(flags, false); err != nil {
+		return l.wrap(err)
+	}
+	l.direction = dIn
+	l.pull = pull
+	l.edge = edge
+	l.flushEvents()
+	return nil
+}
+
+// Read implements gpio.PinIn.
+func (l *GPIOLine) Read() gpio.Level {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.read()
+}
+
+// WaitForEdge does edge detection, returns once one is detected and implements
+// gpio.PinIn.
+//
+// Contrary to sysfs.Pin, edges are queued by the kernel so each call consumes
+// exactly one edge.
+func (l *GPIOLine) WaitForEdge(timeout time.Duration) bool {
+	_, ok := l.ReadEdge(timeout)
+	return ok
+}
+
+// WaitForEdgeContext waits for the next edge until ctx is done; implements
+// gpio.PinInContext.
+func (l *GPIOLine) WaitForEdgeContext(ctx context.Context) bool {
+	f := l.file()
+	if ok, err := l.event.waitContext(ctx); !ok || err != nil {
+		return false
+	}
+	_, err := l.readEvent(f)
+	return err == nil
+}
+
+// ReadEdge waits for the next edge and returns it; implements
+// gpio.PinEdgeEvents.
+//
+// The edges are queued by the kernel and timestamped with CLOCK_MONOTONIC.
+func (l *GPIOLine) ReadEdge(timeout time.Duration) (gpio.EdgeEvent, bool) {
+	// Run lockless, as the normal use is to call in a busy loop. The line
+	// request file descriptor is stable until Close() is called.
+	f := l.file()
+	var ms int
+	if timeout == -1 {
+		ms = -1
+	} else {
+		ms = int(timeout / time.Millisecond)
+	}
+	start := time.Now()
+	for {
+		if nr, err := l.event.wait(ms); err != nil {
+			return gpio.EdgeEvent{}, false
+		} else if nr == 1 {
+			e, err := l.readEvent(f)
+			return e, err == nil
+		}
+		// A signal occurred.
+		if timeout != -1 {
+			ms = int((timeout - time.Since(start)) / time.Millisecond)
+		}
+		if ms <= 0 {
+			return gpio.EdgeEvent{}, false
+		}
+	}
+}
+
+// Pull implements gpio.PinIn.
+//
+// It returns the last pull resistor set via In(). Returns gpio.PullNoChange if
+// the line was never set as input by this process.
+func (l *GPIOLine) Pull() gpio.Pull {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.direction != dIn {
+		return gpio.PullNoChange
+	}
+	return l.pull
+}
+
+// Out sets a line as output; implements gpio.PinOut.
+func (l *GPIOLine) Out(level gpio.Level) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.direction != dOut {
+		if err := l.configure(gpioV2LineFlagOutput, level); err != nil {
+			return l.wrap(err)
+		}
+		l.direction = dOut
+		l.edge = gpio.NoEdge
+		return nil
+	}
+	v := gpioV2LineValues{mask: 1}
+	if level {
+		v.bits = 1
+	}
+	if err := ioctl(l.f.Fd(), gpioV2LineSetValuesIoctl, uintptr(unsafe.Pointer(&v))); err != nil {
+		return l.wrap(err)
+	}
+	return nil
+}
+
+// PWM implements gpio.PinOut.
+func (l *GPIOLine) PWM(duty int) error {
+	return l.wrap(errors.New("pwm is not supported"))
+}
+
+// Close releases the line back to the kernel.
+//
+// The line is requested again on the next call to In() or Out(). It is not a
+// requirement to close before process termination.
+func (l *GPIOLine) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	l.event.close()
+	err := l.f.Close()
+	l.f = nil
+	l.direction = dUnknown
+	l.edge = gpio.NoEdge
+	return err
+}
+
+//
+
+// configure requests the line with the flags specified or changes the
+// configuration of the line if it was already requested.
+//
+// lock must be held.
+func (l *GPIOLine) configure(flags uint64, level gpio.Level) error {
+	var cfg gpioV2LineConfig
+	cfg.flags = flags
+	if flags&gpioV2LineFlagOutput != 0 {
+		cfg.numAttrs = 1
+		cfg.attrs[0].attr.id = gpioV2LineAttrIDOutputValues
+		cfg.attrs[0].mask = 1
+		if level {
+			cfg.attrs[0].attr.value = 1
+		}
+	}
+	if l.f != nil {
+		return ioctl(l.f.Fd(), gpioV2LineSetConfigIoctl, uintptr(unsafe.Pointer(&cfg)))
+	}
+	f, err := l.chip.requestLine(l.offset, &cfg)
+	if err != nil {
+		return err
+	}
+	if err = l.event.makeEventIn(f); err != nil {
+		f.Close()
+		return err
+	}
+	l.f = f
+	return nil
+}
+
+// read returns the current line level.
+//
+// lock must be held.
+func (l *GPIOLine) read() gpio.Level {
+	if l.f == nil {
+		return gpio.Low
+	}
+	v := gpioV2LineValues{mask: 1}
+	if err := ioctl(l.f.Fd(), gpioV2LineGetValuesIoctl, uintptr(unsafe.Pointer(&v))); err != nil {
+		return gpio.Low
+	}
+	return gpio.Level(v.bits&1 != 0)
+}
+
+// file returns the handle to the line request, snapshot under lock.
+//
+// It is used by the functions that run lock-free. If Close() is called
+// concurrently, reading the closed os.File returns an error.
+func (l *GPIOLine) file() *os.File {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f
+}
+
+// flushEvents discards the edges accumulated in the kernel buffer, including
+// the ones from a previous configuration.
+//
+// lock must be held.
+func (l *GPIOLine) flushEvents() {
+	for l.edge != gpio.NoEdge {
+		if nr, err := l.event.wait(0); err != nil || nr != 1 {
+			break
+		}
+		if _, err := l.readEvent(l.f); err != nil {
+			break
+		}
+	}
+}
+
+// readEvent consumes one edge event from the kernel queue via f, the handle
+// to the line request.
+func (l *GPIOLine) readEvent(f *os.File) (gpio.EdgeEvent, error) {
+	if f == nil {
+		return gpio.EdgeEvent{}, errors.New("line is not requested")
+	}
+	var e gpioV2LineEvent
+	b := (*[unsafe.Sizeof(e)]byte)(unsafe.Pointer(&e))[:]
+	n, err := f.Read(b)
+	if err != nil {
+		return gpio.EdgeEvent{}, err
+	}
+	if n != len(b) {
+		return gpio.EdgeEvent{}, fmt.Errorf("short event read: %d bytes", n)
+	}
+	out := gpio.EdgeEvent{Time: time.Duration(e.timestampNs)}
+	if e.id == gpioV2LineEventRisingEdge {
+		out.Edge = gpio.RisingEdge
+		out.Level = gpio.High
+	} else {
+		out.Edge = gpio.FallingEdge
+		out.Level = gpio.Low
+	}
+	return out, nil
+}
+
+func (l *GPIOLine) wrap(err error) error {
+	return fmt.Errorf("sysfs-gpiochip (%s): %v", l, err)
+}
+
+// requestLine requests a single line from the kernel and returns the handle
+// to the request.
+func (c *GPIOChip) requestLine(offset uint32, cfg *gpioV2LineConfig) (*os.File, error) {
+	var req gpioV2LineRequest
+	req.offsets[0] = offset
+	req.numLines = 1
+	copy(req.consumer[:], "periph")
+	req.config = *cfg
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := ioctl(c.f.Fd(), gpioV2GetLineIoctl, uintptr(unsafe.Pointer(&req))); err != nil {
+		if os.IsPermission(err) {
+			return nil, fmt.Errorf("need more access, try as root or setup udev rules: %v", err)
+		}
+		return nil, err
+	}
+	return os.NewFile(uintptr(req.fd), fmt.Sprintf("%s:%d", c.path, offset)), nil
+}
+
+// lineInfo returns the kernel's information about a line.
+func (c *GPIOChip) lineInfo(offset uint32) (*gpioV2LineInfo, error) {
+	info := &gpioV2LineInfo{offset: offset}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := ioctl(c.f.Fd(), gpioV2GetLineInfoIoctl, uintptr(unsafe.Pointer(info))); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// openGPIOChip opens the GPIO character device at path and creates one
+// GPIOLine per line.
+//
+// Lines are numbered like the deprecated sysfs interface would if the chip is
+// also exposed there, otherwise they are numbered starting at next.
+func openGPIOChip(path string, next int) (*GPIOChip, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		if os.IsPermission(err) {
+			return nil, fmt.Errorf("need more access, try as root or setup udev rules: %v", err)
+		}
+		return nil, err
+	}
+	var info gpioChipInfo
+	if err := ioctl(f.Fd(), gpioGetChipInfoIoctl, uintptr(unsafe.Pointer(&info))); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	c := &GPIOChip{
+		name:  cString(info.name[:]),
+		label: cString(info.label[:]),
+		path:  path,
+		f:     f,
+		lines: make([]*GPIOLine, info.lines),
+	}
+	if c.base = findSysfsBase(c.label, int(info.lines)); c.base < 0 {
+		c.base = next
+	}
+	for i := range c.lines {
+		n := c.base + i
+		c.lines[i] = &GPIOLine{
+			number: n,
+			name:   fmt.Sprintf("GPIO%d", n),
+			chip:   c,
+			offset: uint32(i),
+		}
+	}
+	return c, nil
+}
+
+// findSysfsBase returns the base number used by the deprecated
+// /sys/class/gpio interface for the chip with this label, if present.
+//
+// This keeps pin numbers consistent with sysfs.Pin and the CPU drivers.
+func findSysfsBase(label string, ngpio int) int {
+	items, err := filepath.Glob("/sys/class/gpio/gpiochip*")
+	if err != nil {
+		return -1
+	}
+	for _, item := range items {
+		raw, err := readFile(item + "/label")
+		if err != nil || raw != label {
+			continue
+		}
+		if n, err := readInt(item + "/ngpio"); err != nil || n != ngpio {
+			continue
+		}
+		if base, err := readInt(item + "/base"); err == nil {
+			return base
+		}
+	}
+	return -1
+}
+
+// gpioChipPaths returns the GPIO character devices sorted by chip number.
+func gpioChipPaths() []string {
+	prefix := "/dev/gpiochip"
+	items, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		return nil
+	}
+	var nums []int
+	for _, item := range items {
+		if i, err := strconv.Atoi(item[len(prefix):]); err == nil {
+			nums = append(nums, i)
+		}
+	}
+	sort.Ints(nums)
+	out := make([]string, len(nums))
+	for i, n := range nums {
+		out[i] = prefix + strconv.Itoa(n)
+	}
+	return out
+}
+
+// gpioChipUsable returns true if the GPIO character device interface can be
+// used by this process.
+//
+// Both sysfs-gpio and sysfs-gpiochip use it to decide which one registers its
+// pins in gpioreg, so they never conflict.
+func gpioChipUsable() bool {
+	paths := gpioChipPaths()
+	if len(paths) == 0 {
+		return false
+	}
+	f, err := os.OpenFile(paths[0], os.O_RDWR, 0600)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+func readFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var b [64]byte
+	n, err := f.Read(b[:])
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b[:n])), nil
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i != -1 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// GPIO character device IOCTL control codes for the v2 uAPI.
+//
+// Constants and structure definition can be found at
+// /usr/include/linux/gpio.h.
+const (
+	gpioGetChipInfoIoctl     = 0x8044B401
+	gpioV2GetLineInfoIoctl   = 0xC100B405
+	gpioV2GetLineIoctl       = 0xC250B407
+	gpioV2LineSetConfigIoctl = 0xC110B40D
+	gpioV2LineGetValuesIoctl = 0xC010B40E
+	gpioV2LineSetValuesIoctl = 0xC010B40F
+)
+
+// gpio_v2_line_flag
+const (
+	gpioV2LineFlagUsed               = 1 << 0
+	gpioV2LineFlagActiveLow          = 1 << 1
+	gpioV2LineFlagInput              = 1 << 2
+	gpioV2LineFlagOutput             = 1 << 3
+	gpioV2LineFlagEdgeRising         = 1 << 4
+	gpioV2LineFlagEdgeFalling        = 1 << 5
+	gpioV2LineFlagOpenDrain          = 1 << 6
+	gpioV2LineFlagOpenSource         = 1 << 7
+	gpioV2LineFlagBiasPullUp         = 1 << 8
+	gpioV2LineFlagBiasPullDown       = 1 << 9
+	gpioV2LineFlagBiasDisabled       = 1 << 10
+	gpioV2LineFlagEventClockRealtime = 1 << 11
+)
+
+// gpio_v2_line_attr_id
+const (
+	gpioV2LineAttrIDFlags        = 1
+	gpioV2LineAttrIDOutputValues = 2
+	gpioV2LineAttrIDDebounce     = 3
+)
+
+// gpio_v2_line_event_id
+const (
+	gpioV2LineEventRisingEdge  = 1
+	gpioV2LineEventFallingEdge = 2
+)
+
+type gpioChipInfo struct {
+	name  [32]byte
+	label [32]byte
+	lines uint32
+}
+
+type gpioV2LineValues struct {
+	bits uint64
+	mask uint64
+}
+
+type gpioV2LineAttribute struct {
+	id      uint32
+	padding uint32
+	value   uint64 // flags, values or debounce_period_us depending on id
+}
+
+type gpioV2LineConfigAttribute struct {
+	attr gpioV2LineAttribute
+	mask uint64
+}
+
+type gpioV2LineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [10]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	offsets         [64]uint32
+	consumer        [32]byte
+	config          gpioV2LineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+type gpioV2LineInfo struct {
+	name     [32]byte
+	consumer [32]byte
+	offset   uint32
+	numAttrs uint32
+	flags    uint64
+	attrs    [10]gpioV2LineAttribute
+	padding  [4]uint32
+}
+
+type gpioV2LineEvent struct {
+	timestampNs uint64
+	id          uint32
+	offset      uint32
+	seqno       uint32
+	lineSeqno   uint32
+	padding     [6]uint32
+}
+
+// driverGPIOChip implements periph.Driver.
+type driverGPIOChip struct {
+}
+
+func (d *driverGPIOChip) String() string {
+	return "sysfs-gpiochip"
+}
+
+func (d *driverGPIOChip) Prerequisites() []string {
+	return nil
+}
+
+// Init initializes GPIO character device handling code.
+//
+// Uses the GPIO v2 uAPI as described at
+// https://www.kernel.org/doc/Documentation/userspace-api/gpio/chardev.rst
+//
+// This is the replacement for the deprecated /sys/class/gpio interface. It
+// supports pull resistors via bias flags and edge detection without relying
+// on exporting the pin first.
+func (d *driverGPIOChip) Init() (bool, error) {
+	paths := gpioChipPaths()
+	if len(paths) == 0 {
+		return false, errors.New("no GPIO character device found")
+	}
+	if !gpioChipUsable() {
+		return false, fmt.Errorf("can't open %s; try as root or setup udev rules", paths[0])
+	}
+	next := 0
+	for _, path := range paths {
+		c, err := openGPIOChip(path, next)
+		if err != nil {
+			return true, err
+		}
+		GPIOChips = append(GPIOChips, c)
+		if end := c.base + len(c.lines); end > next {
+			next = end
+		}
+		for _, l := range c.lines {
+			if err := gpioreg.Register(l, false); err != nil {
+				return true, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// Close releases all the lines and closes the GPIO character devices.
+func (d *driverGPIOChip) Close() error {
+	var err error
+	for _, c := range GPIOChips {
+		for _, l := range c.lines {
+			if err2 := l.Close(); err == nil {
+				err = err2
+			}
+		}
+		if err2 := c.f.Close(); err == nil {
+			err = err2
+		}
+	}
+	GPIOChips = nil
+	return err
+}
+
+func init() {
+	if isLinux {
+		periph.MustRegister(&driverGPIOChip{})
+	}
+}
+
+var _ periph.DriverCloser = &driverGPIOChip{}
+
+var _ gpio.PinIn = &GPIOLine{}
+var _ gpio.PinOut = &GPIOLine{}
+var _ gpio.PinIO = &GPIOLine{}
+var _ gpio.PinEdgeEvents = &GPIOLine{}
+var _ gpio.PinInContext = &GPIOLine{}
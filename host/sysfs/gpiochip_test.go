@@ -0,0 +1,195 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+func TestGPIOChipStructSizes(t *testing.T) {
+	// The sizes must match /usr/include/linux/gpio.h exactly, as they are
+	// encoded in the ioctl control codes.
+	data := []struct {
+		name     string
+		actual   uintptr
+		expected uintptr
+		ioctl    uint
+	}{
+		{"gpiochip_info", unsafe.Sizeof(gpioChipInfo{}), 68, gpioGetChipInfoIoctl},
+		{"gpio_v2_line_info", unsafe.Sizeof(gpioV2LineInfo{}), 256, gpioV2GetLineInfoIoctl},
+		{"gpio_v2_line_request", unsafe.Sizeof(gpioV2LineRequest{}), 592, gpioV2GetLineIoctl},
+		{"gpio_v2_line_config", unsafe.Sizeof(gpioV2LineConfig{}), 272, gpioV2LineSetConfigIoctl},
+		{"gpio_v2_line_values", unsafe.Sizeof(gpioV2LineValues{}), 16, gpioV2LineGetValuesIoctl},
+		{"gpio_v2_line_values", unsafe.Sizeof(gpioV2LineValues{}), 16, gpioV2LineSetValuesIoctl},
+	}
+	for _, line := range data {
+		if line.actual != line.expected {
+			t.Fatalf("%s: expected %d bytes, got %d", line.name, line.expected, line.actual)
+		}
+		if s := uintptr((line.ioctl >> 16) & 0x3FFF); s != line.expected {
+			t.Fatalf("%s: ioctl 0x%X encodes %d bytes", line.name, line.ioctl, s)
+		}
+	}
+	if s := unsafe.Sizeof(gpioV2LineEvent{}); s != 48 {
+		t.Fatalf("gpio_v2_line_event: expected 48 bytes, got %d", s)
+	}
+}
+
+func TestCString(t *testing.T) {
+	if s := cString([]byte{'a', 'b', 0, 'c'}); s != "ab" {
+		t.Fatal(s)
+	}
+	if s := cString([]byte{'a', 'b'}); s != "ab" {
+		t.Fatal(s)
+	}
+}
+
+func TestGPIOLine_readEvent(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	l := GPIOLine{name: "line", f: r}
+	e := gpioV2LineEvent{timestampNs: 10, id: gpioV2LineEventRisingEdge}
+	if _, err := w.Write((*[unsafe.Sizeof(e)]byte)(unsafe.Pointer(&e))[:]); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := l.readEvent(l.file()); err != nil || out.Edge != gpio.RisingEdge || out.Time != 10 {
+		t.Fatal(out, err)
+	}
+	// Simulate a concurrent Close().
+	l.mu.Lock()
+	l.f.Close()
+	l.f = nil
+	l.mu.Unlock()
+	if _, err := l.readEvent(l.file()); err == nil {
+		t.Fatal("line is closed")
+	}
+}
+
+func TestGPIOLine_flushEvents(t *testing.T) {
+	if !isLinux {
+		t.Skip("epoll is only supported on linux")
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	defer r.Close()
+	l := GPIOLine{name: "line", f: r, edge: gpio.BothEdges}
+	if err := l.event.makeEventIn(r); err != nil {
+		t.Fatal(err)
+	}
+	defer l.event.close()
+	e := gpioV2LineEvent{timestampNs: 10, id: gpioV2LineEventRisingEdge}
+	for i := 0; i < 2; i++ {
+		if _, err := w.Write((*[unsafe.Sizeof(e)]byte)(unsafe.Pointer(&e))[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// In() calls flushEvents() with the lock held.
+	l.mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.flushEvents()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("flushEvents() deadlocked")
+	}
+	l.mu.Unlock()
+	if nr, err := l.event.wait(0); nr != 0 || err != nil {
+		t.Fatal("expected no pending event", nr, err)
+	}
+}
+
+// TestGPIOChipSim runs against a chip created with the kernel gpio-sim
+// module. It is skipped when no such chip is present.
+//
+// See https://www.kernel.org/doc/html/latest/admin-guide/gpio/gpio-sim.html
+// to create one via configfs.
+func TestGPIOChipSim(t *testing.T) {
+	sims, _ := filepath.Glob("/sys/bus/gpio/devices/gpiochip*/sim_gpio0")
+	if len(sims) == 0 {
+		t.Skip("no gpio-sim chip found")
+	}
+	dir := filepath.Dir(sims[0])
+	c, err := openGPIOChip("/dev/"+filepath.Base(dir), 0)
+	if err != nil {
+		t.Skipf("can't open gpio-sim chip: %v", err)
+	}
+	defer c.f.Close()
+	if len(c.lines) == 0 {
+		t.Fatal("expected lines")
+	}
+	l := c.lines[0]
+	defer l.Close()
+	simPull := func(v string) {
+		if err := ioutil.WriteFile(dir+"/sim_gpio0/pull", []byte(v), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	simPull("pull-down")
+	if err := l.In(gpio.PullNoChange, gpio.BothEdges); err != nil {
+		t.Fatal(err)
+	}
+	if l.Read() != gpio.Low {
+		t.Fatal("expected Low")
+	}
+	simPull("pull-up")
+	if e, ok := l.ReadEdge(time.Second); !ok {
+		t.Fatal("expected edge")
+	} else if e.Edge != gpio.RisingEdge || e.Level != gpio.High || e.Time == 0 {
+		t.Fatalf("unexpected edge %s", e)
+	}
+	if l.Read() != gpio.High {
+		t.Fatal("expected High")
+	}
+	if l.WaitForEdge(0) {
+		t.Fatal("unexpected edge")
+	}
+	// Reconfiguring the line with edges pending flushes them.
+	simPull("pull-down")
+	simPull("pull-up")
+	time.Sleep(10 * time.Millisecond)
+	if err := l.In(gpio.PullNoChange, gpio.RisingEdge); err != nil {
+		t.Fatal(err)
+	}
+	if l.WaitForEdge(0) {
+		t.Fatal("unexpected edge")
+	}
+
+	if err := l.Out(gpio.High); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := readFile(dir + "/sim_gpio0/value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw != "1" {
+		t.Fatalf("expected 1, got %q", raw)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The line must have been released.
+	f, err := c.requestLine(0, &gpioV2LineConfig{flags: gpioV2LineFlagInput})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
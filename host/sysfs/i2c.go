@@ -5,6 +5,7 @@
 package sysfs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"unsafe"
 
 	"periph.io/x/periph"
@@ -20,6 +22,7 @@ import (
 	"periph.io/x/periph/conn/gpio/gpioreg"
 	"periph.io/x/periph/conn/i2c"
 	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/i2c/smbus"
 )
 
 // I2C is an open I²C bus via sysfs.
@@ -91,39 +94,71 @@ func (i *I2C) String() string {
 }
 
 // Tx execute a transaction as a single operation unit.
+//
+// When both w and r are empty, a quick write is done, which can be used to
+// probe for the presence of a device.
+//
+// An address between 0x80 and 0x3FF is a 10 bits address, which is only
+// supported if the adapter supports it.
 func (i *I2C) Tx(addr uint16, w, r []byte) error {
-	if addr >= 0x400 || (addr >= 0x80 && i.fn&func10BitAddr == 0) {
-		return nil
-	}
-	if len(w) == 0 && len(r) == 0 {
-		return nil
-	}
+	return i.TxContext(context.Background(), addr, w, r)
+}
 
-	// Convert the messages to the internal format.
-	var buf [2]i2cMsg
+// TxContext execute a transaction as a single operation unit unless ctx is
+// done before it starts; implements i2c.BusContext.
+//
+// The kernel doesn't support aborting a transaction in progress, so ctx is
+// checked only up to the point the transaction is sent to the kernel.
+func (i *I2C) TxContext(ctx context.Context, addr uint16, w, r []byte) error {
+	tenBit := addr >= 0x80
+	var buf [2]i2c.Msg
 	msgs := buf[0:0]
-	if len(w) != 0 {
-		msgs = buf[:1]
-		buf[0].addr = addr
-		buf[0].length = uint16(len(w))
-		buf[0].buf = uintptr(unsafe.Pointer(&w[0]))
+	if len(w) != 0 || len(r) == 0 {
+		// A zero length write message is a quick write; it only sends the
+		// address.
+		msgs = append(msgs, i2c.Msg{Addr: addr, Buf: w, TenBit: tenBit})
 	}
 	if len(r) != 0 {
-		l := len(msgs)
-		msgs = msgs[:l+1] // extend the slice by one
-		buf[l].addr = addr
-		buf[l].flags = flagRD
-		buf[l].length = uint16(len(r))
-		buf[l].buf = uintptr(unsafe.Pointer(&r[0]))
+		msgs = append(msgs, i2c.Msg{Addr: addr, Read: true, Buf: r, TenBit: tenBit})
 	}
-	p := rdwrIoctlData{
-		msgs:  uintptr(unsafe.Pointer(&msgs[0])),
-		nmsgs: uint32(len(msgs)),
+	return i.txMsgs(ctx, msgs)
+}
+
+// TxMsgs implements i2c.BusMsgs.
+//
+// The messages are sent with a single I2C_RDWR ioctl. 10 bits addresses and
+// the NoStart and IgnoreNAK flags are only supported if the adapter supports
+// them.
+func (i *I2C) TxMsgs(msgs []i2c.Msg) error {
+	return i.txMsgs(context.Background(), msgs)
+}
+
+// SMBus implements smbus.Native.
+//
+// It uses the I2C_SMBUS ioctl when the functionality reported by the adapter
+// supports the transaction. Otherwise, or when the address is in use by a
+// kernel driver, it returns false so the transaction is emulated over Tx().
+func (i *I2C) SMBus(addr uint16, read bool, cmd byte, p smbus.Protocol, pec bool, data *smbus.Data) (bool, error) {
+	if addr >= 0x80 || !i.fn.supportsSMBus(read, p, pec) {
+		return false, nil
+	}
+	d := smbusIoctlData{command: cmd, size: uint32(p), data: uintptr(unsafe.Pointer(data))}
+	if read {
+		d.readWrite = 1
 	}
-	pp := uintptr(unsafe.Pointer(&p))
 	i.mu.Lock()
 	defer i.mu.Unlock()
-	return i.ioctl(ioctlRdwr, pp)
+	if err := i.ioctl(ioctlSlave, uintptr(addr)); err != nil {
+		return false, nil
+	}
+	v := uintptr(0)
+	if pec {
+		v = 1
+	}
+	if err := i.ioctl(ioctlPEC, v); err != nil {
+		return true, err
+	}
+	return true, i.ioctl(ioctlSMBus, uintptr(unsafe.Pointer(&d)))
 }
 
 // Speed implements i2c.Bus.
@@ -143,6 +178,20 @@ func (i *I2C) Speed(hz int64) error {
 	return errors.New("sysfs-i2c: not supported")
 }
 
+// Recover implements i2c.BusRecoverer.
+//
+// It requires the SCL and SDA pins to be known and to implement pin.PinFunc,
+// which is the case for the CPU drivers in host/bcm283x and host/allwinner.
+func (i *I2C) Recover() error {
+	i.initPins()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err := i2c.Recover(i.scl, i.sda); err != nil {
+		return fmt.Errorf("sysfs-i2c: %v", err)
+	}
+	return nil
+}
+
 // SCL implements i2c.Pins.
 //
 // It will fail if host.Init() wasn't called. host.Init() is transparently
@@ -163,13 +212,75 @@ func (i *I2C) SDA() gpio.PinIO {
 
 // Private details.
 
+func (i *I2C) txMsgs(ctx context.Context, msgs []i2c.Msg) error {
+	if len(msgs) == 0 {
+		return errors.New("sysfs-i2c: no message to send")
+	}
+	if len(msgs) > rdwrMaxMsgs {
+		return fmt.Errorf("sysfs-i2c: too many messages; %d > %d", len(msgs), rdwrMaxMsgs)
+	}
+	// Convert the messages to the internal format.
+	k := make([]i2cMsg, len(msgs))
+	for j := range msgs {
+		if err := i.fn.toMsg(&msgs[j], &k[j]); err != nil {
+			return err
+		}
+	}
+	p := rdwrIoctlData{
+		msgs:  uintptr(unsafe.Pointer(&k[0])),
+		nmsgs: uint32(len(k)),
+	}
+	pp := uintptr(unsafe.Pointer(&p))
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return i.ioctl(ioctlRdwr, pp)
+}
+
 func (i *I2C) ioctl(op uint, arg uintptr) error {
 	if err := ioctl(i.f.Fd(), op, arg); err != nil {
+		if errno, ok := err.(syscall.Errno); ok {
+			return &i2cError{errno}
+		}
 		return fmt.Errorf("sysfs-i2c: ioctl: %v", err)
 	}
 	return nil
 }
 
+// i2cError is an error returned by an I²C adapter driver.
+//
+// It implements the error interfaces of package i2c based on the errno, as
+// documented at https://www.kernel.org/doc/Documentation/i2c/fault-codes.
+type i2cError struct {
+	errno syscall.Errno
+}
+
+func (e *i2cError) Error() string {
+	return "sysfs-i2c: ioctl: " + e.errno.Error()
+}
+
+// AddressNACK implements i2c.AddressNACKError.
+func (e *i2cError) AddressNACK() bool {
+	return e.errno == errnoAddressNACK
+}
+
+// DataNACK implements i2c.DataNACKError.
+func (e *i2cError) DataNACK() bool {
+	return e.errno == errnoDataNACK
+}
+
+// ArbitrationLost implements i2c.ArbitrationLostError.
+func (e *i2cError) ArbitrationLost() bool {
+	return e.errno == errnoArbitrationLost
+}
+
+// Timeout implements i2c.TimeoutError.
+func (e *i2cError) Timeout() bool {
+	return e.errno == errnoTimeout
+}
+
 func (i *I2C) initPins() {
 	i.mu.Lock()
 	if i.scl == nil {
@@ -194,6 +305,8 @@ const (
 	ioctlTenBits = 0x704 // TODO(maruel): Expose this but the header says it's broken (!?)
 	ioctlFuncs   = 0x705
 	ioctlRdwr    = 0x707
+	ioctlPEC     = 0x708
+	ioctlSMBus   = 0x720
 )
 
 // flags
@@ -291,6 +404,88 @@ func (f functionality) String() string {
 	return strings.Join(out, "|")
 }
 
+// supportsSMBus returns true if the SMBus transaction can be done via the
+// I2C_SMBUS ioctl.
+func (f functionality) supportsSMBus(read bool, p smbus.Protocol, pec bool) bool {
+	if pec && f&funcSMBusPEC == 0 {
+		return false
+	}
+	var need functionality
+	switch p {
+	case smbus.Quick:
+		need = funcSMBusQuick
+	case smbus.Byte:
+		need = funcSMBusWriteByte
+		if read {
+			need = funcSMBusReadByte
+		}
+	case smbus.ByteData:
+		need = funcSMBusWriteByteData
+		if read {
+			need = funcSMBusReadByteData
+		}
+	case smbus.WordData:
+		need = funcSMBusWriteWordData
+		if read {
+			need = funcSMBusReadWordData
+		}
+	case smbus.ProcCall:
+		need = funcSMBusProcCall
+	case smbus.BlockData:
+		need = funcSMBusWriteBlockData
+		if read {
+			need = funcSMBusReadBlockData
+		}
+	default:
+		return false
+	}
+	return f&need != 0
+}
+
+// toMsg converts m to the kernel format into k.
+//
+// It returns an error if the adapter doesn't support the message.
+func (f functionality) toMsg(m *i2c.Msg, k *i2cMsg) error {
+	if m.TenBit {
+		if m.Addr > 0x3FF {
+			return fmt.Errorf("sysfs-i2c: invalid 10 bits address 0x%X", m.Addr)
+		}
+		if f&func10BitAddr == 0 {
+			return fmt.Errorf("sysfs-i2c: can't use 10 bits address 0x%X; the adapter doesn't support 10 bits addressing", m.Addr)
+		}
+		k.flags |= flagTEN
+	} else if m.Addr > 0x7F {
+		return fmt.Errorf("sysfs-i2c: invalid 7 bits address 0x%X", m.Addr)
+	}
+	if m.NoStart {
+		if f&funcNOSTART == 0 {
+			return errors.New("sysfs-i2c: the adapter doesn't support NoStart")
+		}
+		k.flags |= flagNOSTART
+	}
+	if m.IgnoreNAK {
+		if f&funcProtocolMangling == 0 {
+			return errors.New("sysfs-i2c: the adapter doesn't support IgnoreNAK")
+		}
+		k.flags |= flagIgnoreNAK
+	}
+	if len(m.Buf) > 0xFFFF {
+		return fmt.Errorf("sysfs-i2c: message of %d bytes is too long", len(m.Buf))
+	}
+	if m.Read {
+		k.flags |= flagRD
+	}
+	k.addr = m.Addr
+	k.length = uint16(len(m.Buf))
+	if len(m.Buf) != 0 {
+		k.buf = uintptr(unsafe.Pointer(&m.Buf[0]))
+	}
+	return nil
+}
+
+// rdwrMaxMsgs is I2C_RDWR_IOCTL_MAX_MSGS.
+const rdwrMaxMsgs = 42
+
 type rdwrIoctlData struct {
 	msgs  uintptr // Pointer to i2cMsg
 	nmsgs uint32
@@ -303,6 +498,14 @@ type i2cMsg struct {
 	buf    uintptr
 }
 
+// smbusIoctlData is struct i2c_smbus_ioctl_data.
+type smbusIoctlData struct {
+	readWrite uint8
+	command   uint8
+	size      uint32
+	data      uintptr // Pointer to smbus.Data
+}
+
 // driverI2C implements periph.Driver.
 type driverI2C struct {
 }
@@ -355,3 +558,11 @@ func init() {
 }
 
 var _ i2c.Bus = &I2C{}
+var _ i2c.BusContext = &I2C{}
+var _ i2c.BusMsgs = &I2C{}
+var _ i2c.BusRecoverer = &I2C{}
+var _ smbus.Native = &I2C{}
+var _ i2c.AddressNACKError = &i2cError{}
+var _ i2c.DataNACKError = &i2cError{}
+var _ i2c.ArbitrationLostError = &i2cError{}
+var _ i2c.TimeoutError = &i2cError{}
@@ -0,0 +1,43 @@
+// Copyright 2017 The Periph Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package sysfs
+
+import (
+	"syscall"
+	"testing"
+
+	"periph.io/x/periph/conn/i2c"
+)
+
+func TestI2CError(t *testing.T) {
+	data := []struct {
+		errno                                    syscall.Errno
+		addrNACK, dataNACK, arbitration, timeout bool
+	}{
+		{syscall.ENXIO, true, false, false, false},
+		{syscall.EREMOTEIO, false, true, false, false},
+		{syscall.EAGAIN, false, false, true, false},
+		{syscall.ETIMEDOUT, false, false, false, true},
+		{syscall.EIO, false, false, false, false},
+	}
+	for i, line := range data {
+		var err error = &i2cError{line.errno}
+		if err.Error() != "sysfs-i2c: ioctl: "+line.errno.Error() {
+			t.Fatalf("#%d: %s", i, err)
+		}
+		if e, ok := err.(i2c.AddressNACKError); !ok || e.AddressNACK() != line.addrNACK {
+			t.Fatalf("#%d: AddressNACK", i)
+		}
+		if e, ok := err.(i2c.DataNACKError); !ok || e.DataNACK() != line.dataNACK {
+			t.Fatalf("#%d: DataNACK", i)
+		}
+		if e, ok := err.(i2c.ArbitrationLostError); !ok || e.ArbitrationLost() != line.arbitration {
+			t.Fatalf("#%d: ArbitrationLost", i)
+		}
+		if e, ok := err.(i2c.TimeoutError); !ok || e.Timeout() != line.timeout {
+			t.Fatalf("#%d: Timeout", i)
+		}
+	}
+}
@@ -3,3 +3,79 @@
 // that can be found in the LICENSE file.
 
 package sysfs
+
+import (
+	"testing"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/smbus"
+)
+
+func TestFunctionalitySMBus(t *testing.T) {
+	data := []struct {
+		f        functionality
+		read     bool
+		p        smbus.Protocol
+		pec      bool
+		expected bool
+	}{
+		{funcSMBusQuick, false, smbus.Quick, false, true},
+		{funcSMBusReadByte, true, smbus.Byte, false, true},
+		{funcSMBusReadByte, false, smbus.Byte, false, false},
+		{funcSMBusWriteByteData, false, smbus.ByteData, false, true},
+		{funcSMBusWriteByteData, true, smbus.ByteData, false, false},
+		{funcSMBusReadWordData, true, smbus.WordData, false, true},
+		{funcSMBusProcCall, false, smbus.ProcCall, false, true},
+		{funcSMBusReadBlockData, true, smbus.BlockData, false, true},
+		{funcSMBusReadBlockData, true, smbus.BlockData, true, false},
+		{funcSMBusReadBlockData | funcSMBusPEC, true, smbus.BlockData, true, true},
+		{funcI2C, false, smbus.WordData, false, false},
+		{0xFFFFFFFF, false, smbus.Protocol(8), false, false},
+	}
+	for i, line := range data {
+		if actual := line.f.supportsSMBus(line.read, line.p, line.pec); actual != line.expected {
+			t.Fatalf("#%d: %s %t %s %t: %t", i, line.f, line.read, line.p, line.pec, actual)
+		}
+	}
+}
+
+func TestFunctionalityToMsg(t *testing.T) {
+	data := []struct {
+		f        functionality
+		m        i2c.Msg
+		flags    uint16
+		expected string
+	}{
+		{funcI2C, i2c.Msg{Addr: 0x76, Buf: []byte{1}}, 0, ""},
+		{funcI2C, i2c.Msg{Addr: 0x76, Read: true, Buf: []byte{1}}, flagRD, ""},
+		{funcI2C, i2c.Msg{Addr: 0x76}, 0, ""},
+		{funcI2C, i2c.Msg{Addr: 0x80}, 0, "sysfs-i2c: invalid 7 bits address 0x80"},
+		{funcI2C, i2c.Msg{Addr: 0x150, TenBit: true}, 0, "sysfs-i2c: can't use 10 bits address 0x150; the adapter doesn't support 10 bits addressing"},
+		{funcI2C | func10BitAddr, i2c.Msg{Addr: 0x150, TenBit: true}, flagTEN, ""},
+		{funcI2C | func10BitAddr, i2c.Msg{Addr: 0x400, TenBit: true}, 0, "sysfs-i2c: invalid 10 bits address 0x400"},
+		{funcI2C, i2c.Msg{Addr: 0x76, NoStart: true}, 0, "sysfs-i2c: the adapter doesn't support NoStart"},
+		{funcI2C | funcNOSTART, i2c.Msg{Addr: 0x76, NoStart: true}, flagNOSTART, ""},
+		{funcI2C, i2c.Msg{Addr: 0x76, IgnoreNAK: true}, 0, "sysfs-i2c: the adapter doesn't support IgnoreNAK"},
+		{funcI2C | funcProtocolMangling, i2c.Msg{Addr: 0x76, Read: true, IgnoreNAK: true}, flagIgnoreNAK | flagRD, ""},
+		{funcI2C, i2c.Msg{Addr: 0x76, Buf: make([]byte, 0x10000)}, 0, "sysfs-i2c: message of 65536 bytes is too long"},
+	}
+	for i, line := range data {
+		var k i2cMsg
+		err := line.f.toMsg(&line.m, &k)
+		if line.expected != "" {
+			if err == nil || err.Error() != line.expected {
+				t.Fatalf("#%d: %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if k.addr != line.m.Addr || k.flags != line.flags || int(k.length) != len(line.m.Buf) {
+			t.Fatalf("#%d: %#v", i, k)
+		}
+		if (k.buf != 0) != (len(line.m.Buf) != 0) {
+			t.Fatalf("#%d: %#v", i, k)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -45,8 +46,10 @@ type LED struct {
 	name   string
 	root   string
 
-	mu          sync.Mutex
-	fBrightness *os.File // handle to /sys/class/gpio/gpio*/direction; never closed
+	mu            sync.Mutex
+	fBrightness   *os.File // handle to /sys/class/leds/*/brightness; closed by periph.Shutdown()
+	maxBrightness int      // content of max_brightness
+	blinking      bool     // set when the timer trigger was enabled by PWMPeriod
 }
 
 // Name returns the pin name.
@@ -122,6 +125,9 @@ func (l *LED) Out(level gpio.Level) error {
 	}
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	if err = l.stopBlinking(); err != nil {
+		return err
+	}
 	if _, err = l.fBrightness.Seek(0, 0); err != nil {
 		return err
 	}
@@ -133,9 +139,62 @@ func (l *LED) Out(level gpio.Level) error {
 	return err
 }
 
-// PWM implements gpio.PinOut.
+// PWM sets the LED brightness proportional to duty and implements
+// gpio.PinOut.
+//
+// The kernel driver controls the frequency. Many LEDs only support on and off,
+// in which case the duty is rounded to either.
 func (l *LED) PWM(duty int) error {
-	return errors.New("sysfs-led: pwm is not supported")
+	if duty < 0 || duty > gpio.Max {
+		return fmt.Errorf("sysfs-led: invalid duty %d", duty)
+	}
+	err := l.open()
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err = l.stopBlinking(); err != nil {
+		return err
+	}
+	if _, err = l.fBrightness.Seek(0, 0); err != nil {
+		return err
+	}
+	b := (duty*l.maxBrightness + gpio.Half) / gpio.Max
+	_, err = l.fBrightness.Write([]byte(strconv.Itoa(b)))
+	return err
+}
+
+// PWMPeriod blinks the LED with the kernel's timer trigger and implements
+// gpio.PinPWM.
+//
+// The kernel times the blinking with a resolution of 1ms, which is the
+// resolution of the period and of the duration of each phase.
+func (l *LED) PWMPeriod(duty int, period time.Duration) (time.Duration, error) {
+	if duty < 0 || duty > gpio.Max {
+		return 0, fmt.Errorf("sysfs-led: invalid duty %d", duty)
+	}
+	total := (period + time.Millisecond/2) / time.Millisecond
+	if total < 1 {
+		return 0, fmt.Errorf("sysfs-led: period %s is too short", period)
+	}
+	on := (int64(total)*int64(duty) + gpio.Half) / gpio.Max
+	if err := l.open(); err != nil {
+		return 0, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.write("trigger", "timer"); err != nil {
+		return 0, err
+	}
+	l.blinking = true
+	if err := l.write("delay_on", strconv.FormatInt(on, 10)); err != nil {
+		return 0, err
+	}
+	if err := l.write("delay_off", strconv.FormatInt(int64(total)-on, 10)); err != nil {
+		return 0, err
+	}
+	return total * time.Millisecond, nil
 }
 
 //
@@ -151,10 +210,45 @@ func (l *LED) open() error {
 			// Retry with read-only. This is the default setting.
 			l.fBrightness, err = os.OpenFile(p, os.O_RDONLY, 0600)
 		}
+		if err == nil {
+			l.maxBrightness = 255
+			if raw, err2 := readFile(l.root + "max_brightness"); err2 == nil {
+				if i, err2 := strconv.Atoi(raw); err2 == nil {
+					l.maxBrightness = i
+				}
+			}
+		}
 	}
 	return err
 }
 
+// stopBlinking disables the timer trigger enabled by PWMPeriod.
+//
+// l.mu must be held.
+func (l *LED) stopBlinking() error {
+	if !l.blinking {
+		return nil
+	}
+	if err := l.write("trigger", "none"); err != nil {
+		return err
+	}
+	l.blinking = false
+	return nil
+}
+
+// write writes to one of the LED's attribute file.
+func (l *LED) write(name, value string) error {
+	f, err := os.OpenFile(l.root+name, os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("sysfs-led: %v", err)
+	}
+	defer f.Close()
+	if _, err = f.Write([]byte(value)); err != nil {
+		return fmt.Errorf("sysfs-led: %v", err)
+	}
+	return nil
+}
+
 // driverLED implements periph.Driver.
 type driverLED struct {
 }
@@ -193,12 +287,32 @@ func (d *driverLED) Init() (bool, error) {
 	return true, nil
 }
 
+// Close closes the file handles of all the LEDs.
+func (d *driverLED) Close() error {
+	var err error
+	for _, l := range LEDs {
+		l.mu.Lock()
+		if l.fBrightness != nil {
+			if err2 := l.fBrightness.Close(); err == nil {
+				err = err2
+			}
+			l.fBrightness = nil
+		}
+		l.mu.Unlock()
+	}
+	LEDs = nil
+	return err
+}
+
 func init() {
 	if isLinux {
 		periph.MustRegister(&driverLED{})
 	}
 }
 
+var _ periph.DriverCloser = &driverLED{}
+
 var _ gpio.PinIn = &LED{}
 var _ gpio.PinOut = &LED{}
 var _ gpio.PinIO = &LED{}
+var _ gpio.PinPWM = &LED{}
@@ -6,8 +6,11 @@ package sysfs
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
 	"testing"
+	"time"
 
 	"periph.io/x/periph/conn/gpio"
 )
@@ -32,3 +35,57 @@ func TestLEDByName(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestLED_PWM(t *testing.T) {
+	root, err := ioutil.TempDir("", "periph_led")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	root += "/"
+	for name, v := range map[string]string{"brightness": "0", "max_brightness": "100\n", "trigger": "", "delay_on": "", "delay_off": ""} {
+		if err := ioutil.WriteFile(root+name, []byte(v), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		b, err := ioutil.ReadFile(root + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	l := &LED{name: "LED0", root: root}
+
+	if err := l.PWM(gpio.Max / 4); err != nil {
+		t.Fatal(err)
+	}
+	if s := read("brightness"); s != "25" {
+		t.Fatal(s)
+	}
+	if l.PWM(-1) == nil || l.PWM(gpio.Max+1) == nil {
+		t.Fatal("invalid duty")
+	}
+
+	d, err := l.PWMPeriod(gpio.Max/4, 1000400*time.Microsecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != time.Second {
+		t.Fatal(d)
+	}
+	if s := read("trigger") + "/" + read("delay_on") + "/" + read("delay_off"); s != "timer/250/750" {
+		t.Fatal(s)
+	}
+	if _, err := l.PWMPeriod(gpio.Half, time.Microsecond); err == nil {
+		t.Fatal("period too short")
+	}
+
+	// Out() stops the blinking.
+	if err := l.Out(gpio.Low); err != nil {
+		t.Fatal(err)
+	}
+	if s := read("trigger"); s != "none" {
+		t.Fatal(s)
+	}
+}
@@ -5,15 +5,18 @@
 package sysfs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unsafe"
 
 	"periph.io/x/periph"
@@ -30,6 +33,10 @@ import (
 //
 // The resulting object is safe for concurrent use.
 //
+// Transfers larger than the spidev buffer size, as read from
+// /sys/module/spidev/parameters/bufsiz, are split transparently. See
+// MaxTxSize().
+//
 // busNumber is the bus number as exported by deffs. For example if the path is
 // /dev/spidev0.1, busNumber should be 0 and chipSelect should be 1.
 func NewSPI(busNumber, chipSelect int) (*SPI, error) {
@@ -45,9 +52,11 @@ type SPI struct {
 	f          *os.File
 	busNumber  int
 	chipSelect int
+	maxTxSize  int
 
 	sync.Mutex
 	initialized bool
+	mode        spi.Mode
 	maxHzBus    int64
 	maxHzDev    int64
 	clk         gpio.PinOut
@@ -68,7 +77,7 @@ func newSPI(busNumber, chipSelect int) (*SPI, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &SPI{f: f, busNumber: busNumber, chipSelect: chipSelect}, nil
+	return &SPI{f: f, busNumber: busNumber, chipSelect: chipSelect, maxTxSize: spidevBufSize()}, nil
 }
 
 // Close closes the handle to the SPI driver. It is not a requirement to close
@@ -102,13 +111,21 @@ func (s *SPI) Speed(maxHz int64) error {
 // DevParams implements spi.Conn.
 //
 // It must be called before any I/O.
+//
+// spi.HalfDuplex, spi.LSBFirst and spi.NoCS are mapped to the spidev flags
+// SPI_3WIRE, SPI_LSB_FIRST and SPI_NO_CS. Not all SPI controllers support
+// them, in which case an error is returned.
 func (s *SPI) DevParams(maxHz int64, mode spi.Mode, bits int) error {
-	if bits < 1 || bits > 256 {
+	if bits < 1 || bits > 32 {
 		return fmt.Errorf("sysfs-spi: invalid bits %d", bits)
 	}
 	if maxHz < 0 {
 		return fmt.Errorf("sysfs-spi: invalid speed %d", maxHz)
 	}
+	m, err := toSpidevMode(mode)
+	if err != nil {
+		return err
+	}
 	s.Lock()
 	defer s.Unlock()
 	if s.initialized {
@@ -117,16 +134,34 @@ func (s *SPI) DevParams(maxHz int64, mode spi.Mode, bits int) error {
 	s.initialized = true
 	s.maxHzDev = maxHz
 	if s.maxHzDev != 0 && (s.maxHzBus == 0 || s.maxHzDev < s.maxHzBus) {
-		return s.setFlag(spiIOCMaxSpeedHz, uint64(maxHz))
+		if err := s.setFlag(spiIOCMaxSpeedHz, uint64(maxHz)); err != nil {
+			return err
+		}
+	}
+	if err := s.setFlag(spiIOCMode, uint64(m)); err != nil {
+		return err
 	}
-	if err := s.setFlag(spiIOCMode, uint64(mode)); err != nil {
+	if err := s.setFlag(spiIOCBitsPerWord, uint64(bits)); err != nil {
 		return err
 	}
-	return s.setFlag(spiIOCBitsPerWord, uint64(bits))
+	s.mode = mode
+	return nil
+}
+
+// MaxTxSize implements conn.Limits.
+//
+// It is the spidev buffer size. Larger transfers done via Tx(), Read() or
+// Write() are split in multiple messages, hinting the kernel to keep CS
+// asserted in between. TxPackets() doesn't split transfers.
+func (s *SPI) MaxTxSize() int {
+	return s.maxTxSize
 }
 
 // Read implements io.Reader.
 func (s *SPI) Read(b []byte) (int, error) {
+	if len(b) > s.maxTxSize {
+		return s.txLarge(spi.Packet{R: b})
+	}
 	s.Lock()
 	defer s.Unlock()
 	if !s.initialized {
@@ -137,6 +172,9 @@ func (s *SPI) Read(b []byte) (int, error) {
 
 // Write implements io.Writer.
 func (s *SPI) Write(b []byte) (int, error) {
+	if len(b) > s.maxTxSize {
+		return s.txLarge(spi.Packet{W: b})
+	}
 	s.Lock()
 	defer s.Unlock()
 	if !s.initialized {
@@ -146,7 +184,22 @@ func (s *SPI) Write(b []byte) (int, error) {
 }
 
 // Tx sends and receives data simultaneously.
+//
+// In spi.HalfDuplex mode, w is sent first then r is read. Transfers larger
+// than MaxTxSize() are split.
 func (s *SPI) Tx(w, r []byte) error {
+	return s.TxContext(context.Background(), w, r)
+}
+
+// TxContext sends and receives data simultaneously unless ctx is done before
+// the transfer starts; implements conn.ConnContext.
+//
+// The kernel doesn't support aborting a transfer in progress, so ctx is
+// checked only up to the point the transfer is sent to the kernel.
+func (s *SPI) TxContext(ctx context.Context, w, r []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if len(w) == 0 {
 		if len(r) == 0 {
 			return errors.New("Tx with empty buffers")
@@ -156,26 +209,61 @@ func (s *SPI) Tx(w, r []byte) error {
 	} else if len(r) == 0 {
 		_, err := s.Write(w)
 		return err
+	}
+	// The number of bits per word is left to 0 so the value set in DevParams()
+	// is used.
+	p := []spi.Packet{{W: w, R: r}}
+	if s.Duplex() == conn.Half {
+		// Write then read, without deasserting CS in between.
+		p = []spi.Packet{{W: w}, {R: r}}
 	} else if len(w) != len(r) {
 		return errors.New("Tx with zero or non-equal length w&r slices")
 	}
-	p := spiIOCTransfer{
-		tx:          uint64(uintptr(unsafe.Pointer(&w[0]))),
-		rx:          uint64(uintptr(unsafe.Pointer(&r[0]))),
-		length:      uint32(len(w)),
-		bitsPerWord: 8, // s.bitsPerWord?
+	t, err := chunkPackets(p, s.maxTxSize)
+	if err != nil {
+		return err
 	}
-	s.Lock()
-	defer s.Unlock()
-	if !s.initialized {
-		return errors.New("sysfs-spi: DevParams wasn't called")
+	return s.txPackets(ctx, t)
+}
+
+// TxPackets implements spi.ConnPackets.
+//
+// The packets are sent with a single SPI_IOC_MESSAGE ioctl. In
+// spi.HalfDuplex mode, a packet cannot both write and read.
+func (s *SPI) TxPackets(p []spi.Packet) error {
+	if err := spi.ValidatePackets(p); err != nil {
+		return err
+	}
+	if len(p) > spiMaxTransfers {
+		return fmt.Errorf("sysfs-spi: too many packets; %d > %d", len(p), spiMaxTransfers)
+	}
+	half := s.Duplex() == conn.Half
+	total := 0
+	t := make([]spiIOCTransfer, len(p))
+	for i := range p {
+		if half && len(p[i].W) != 0 && len(p[i].R) != 0 {
+			return fmt.Errorf("sysfs-spi: packet #%d can't both write and read in half-duplex mode", i)
+		}
+		if err := t[i].init(&p[i]); err != nil {
+			return err
+		}
+		total += int(t[i].length)
 	}
-	return s.ioctl(spiIOCTx|0x40000000, unsafe.Pointer(&p))
+	if total > s.maxTxSize {
+		return fmt.Errorf("sysfs-spi: packets total %d bytes, more than the %d bytes limit", total, s.maxTxSize)
+	}
+	return s.txPackets(context.Background(), t)
 }
 
 // Duplex implements spi.Conn.
+//
+// It returns conn.Half when DevParams() was called with spi.HalfDuplex.
 func (s *SPI) Duplex() conn.Duplex {
-	// If half-duplex SPI is ever supported, change this code.
+	s.Lock()
+	defer s.Unlock()
+	if s.mode&spi.HalfDuplex != 0 {
+		return conn.Half
+	}
 	return conn.Full
 }
 
@@ -221,9 +309,35 @@ const (
 	spiIOCMode        = 0x16B01
 	spiIOCBitsPerWord = 0x16B03
 	spiIOCMaxSpeedHz  = 0x46B04
-	spiIOCTx          = 0x206B00
 )
 
+// toSpidevMode converts mode to the value for SPI_IOC_WR_MODE.
+func toSpidevMode(mode spi.Mode) (spi.Mode, error) {
+	if mode&^(spi.Mode3|spi.HalfDuplex|spi.LSBFirst|spi.NoCS) != 0 {
+		return 0, fmt.Errorf("sysfs-spi: invalid mode 0x%x", int(mode))
+	}
+	m := mode & spi.Mode3
+	if mode&spi.HalfDuplex != 0 {
+		m |= threeWire
+	}
+	if mode&spi.LSBFirst != 0 {
+		m |= lSBFirst
+	}
+	if mode&spi.NoCS != 0 {
+		m |= noCS
+	}
+	return m, nil
+}
+
+// spiMaxTransfers is the maximum number of transfers in a SPI_IOC_MESSAGE
+// ioctl, as the size is encoded in 14 bits.
+const spiMaxTransfers = (1<<14 - 1) / 32
+
+// spiIOCMessage returns SPI_IOC_MESSAGE(n).
+func spiIOCMessage(n int) uint {
+	return 0x40000000 | uint(n*32)<<16 | 0x6B00
+}
+
 type spiIOCTransfer struct {
 	tx          uint64 // Pointer to byte slice
 	rx          uint64 // Pointer to byte slice
@@ -237,6 +351,132 @@ type spiIOCTransfer struct {
 	pad         uint16
 }
 
+// init initializes the transfer from p.
+//
+// p must have been validated with spi.ValidatePackets() and must stay alive
+// until the transfer is done.
+func (t *spiIOCTransfer) init(p *spi.Packet) error {
+	if p.Hz > math.MaxUint32 {
+		return fmt.Errorf("sysfs-spi: invalid speed %d", p.Hz)
+	}
+	if p.BitsPerWord > math.MaxUint8 {
+		return fmt.Errorf("sysfs-spi: invalid bits %d", p.BitsPerWord)
+	}
+	us := (p.Delay + time.Microsecond - 1) / time.Microsecond
+	if us > math.MaxUint16 {
+		return fmt.Errorf("sysfs-spi: invalid delay %s", p.Delay)
+	}
+	if len(p.W) != 0 {
+		t.tx = uint64(uintptr(unsafe.Pointer(&p.W[0])))
+		t.length = uint32(len(p.W))
+	}
+	if len(p.R) != 0 {
+		t.rx = uint64(uintptr(unsafe.Pointer(&p.R[0])))
+		t.length = uint32(len(p.R))
+	}
+	t.speedHz = uint32(p.Hz)
+	t.delayUsecs = uint16(us)
+	t.bitsPerWord = uint8(p.BitsPerWord)
+	if p.CSChange {
+		t.csChange = 1
+	}
+	return nil
+}
+
+// chunkPackets converts p to transfers of at most max bytes.
+//
+// Packets are split at a multiple of 4 bytes so words of any size are never
+// split. p must not use Delay nor CSChange and must stay alive until the
+// transfers are done.
+func chunkPackets(p []spi.Packet, max int) ([]spiIOCTransfer, error) {
+	chunk := max &^ 3
+	if chunk == 0 {
+		return nil, fmt.Errorf("sysfs-spi: invalid buffer size %d", max)
+	}
+	var t []spiIOCTransfer
+	for i := range p {
+		w, r := p[i].W, p[i].R
+		for len(w) != 0 || len(r) != 0 {
+			var c spi.Packet
+			c.W, w = splitAt(w, chunk)
+			c.R, r = splitAt(r, chunk)
+			var x spiIOCTransfer
+			if err := x.init(&c); err != nil {
+				return nil, err
+			}
+			t = append(t, x)
+		}
+	}
+	return t, nil
+}
+
+// splitAt returns the first n bytes of b and the remainder.
+func splitAt(b []byte, n int) ([]byte, []byte) {
+	if len(b) <= n {
+		return b, nil
+	}
+	return b[:n], b[n:]
+}
+
+// msgLen returns the number of transfers at the start of t that fit in a
+// single message of at most max bytes. It is at least 1.
+func msgLen(t []spiIOCTransfer, max int) int {
+	n, size := 1, int(t[0].length)
+	for ; n < len(t) && size+int(t[n].length) <= max; n++ {
+		size += int(t[n].length)
+	}
+	return n
+}
+
+// txLarge does a transfer larger than the spidev buffer size for Read() and
+// Write().
+func (s *SPI) txLarge(p spi.Packet) (int, error) {
+	t, err := chunkPackets([]spi.Packet{p}, s.maxTxSize)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.txPackets(context.Background(), t); err != nil {
+		return 0, err
+	}
+	return len(p.W) + len(p.R), nil
+}
+
+// txPackets sends t in as many SPI_IOC_MESSAGE ioctls as needed to stay
+// within the spidev buffer size.
+//
+// ctx is only checked before the first ioctl, as cancelling in between would
+// leave CS asserted.
+func (s *SPI) txPackets(ctx context.Context, t []spiIOCTransfer) error {
+	s.Lock()
+	defer s.Unlock()
+	if !s.initialized {
+		return errors.New("sysfs-spi: DevParams wasn't called")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for len(t) != 0 {
+		n := msgLen(t, s.maxTxSize)
+		if n < len(t) {
+			// Hint the kernel to keep CS asserted until the next message.
+			t[n-1].csChange = 1
+		}
+		if err := s.ioctl(spiIOCMessage(n), unsafe.Pointer(&t[0])); err != nil {
+			return err
+		}
+		t = t[n:]
+	}
+	return nil
+}
+
+// spidevBufSize returns the maximum size of a spidev message.
+func spidevBufSize() int {
+	if n, err := readInt("/sys/module/spidev/parameters/bufsiz"); err == nil && n >= 4 {
+		return n
+	}
+	return 4096
+}
+
 func (s *SPI) setFlag(op uint, arg uint64) error {
 	if err := s.ioctl(op|0x40000000, unsafe.Pointer(&arg)); err != nil {
 		return err
@@ -360,5 +600,8 @@ func init() {
 }
 
 var _ spi.Conn = &SPI{}
+var _ conn.ConnContext = &SPI{}
+var _ spi.ConnPackets = &SPI{}
+var _ conn.Limits = &SPI{}
 var _ io.Reader = &SPI{}
 var _ io.Writer = &SPI{}
@@ -3,3 +3,165 @@
 // that can be found in the LICENSE file.
 
 package sysfs
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/spi"
+)
+
+func TestSPIIOCMessage(t *testing.T) {
+	if s := unsafe.Sizeof(spiIOCTransfer{}); s != 32 {
+		t.Fatalf("spi_ioc_transfer: expected 32 bytes, got %d", s)
+	}
+	if v := spiIOCMessage(1); v != 0x40206B00 {
+		t.Fatalf("0x%X", v)
+	}
+	if v := spiIOCMessage(3); v != 0x40606B00 {
+		t.Fatalf("0x%X", v)
+	}
+	if v := spiIOCMessage(spiMaxTransfers); (v>>16)&0x3FFF != spiMaxTransfers*32 {
+		t.Fatalf("0x%X", v)
+	}
+}
+
+func TestSPIIOCTransferInit(t *testing.T) {
+	w := []byte{1, 2}
+	r := make([]byte, 3)
+	var x spiIOCTransfer
+	p := spi.Packet{W: w, Hz: 1000000, BitsPerWord: 9, Delay: 1500 * time.Nanosecond, CSChange: true}
+	if err := x.init(&p); err != nil {
+		t.Fatal(err)
+	}
+	if x.tx == 0 || x.rx != 0 || x.length != 2 || x.speedHz != 1000000 || x.bitsPerWord != 9 || x.delayUsecs != 2 || x.csChange != 1 {
+		t.Fatalf("%#v", x)
+	}
+	x = spiIOCTransfer{}
+	if err := x.init(&spi.Packet{R: r}); err != nil {
+		t.Fatal(err)
+	}
+	if x.tx != 0 || x.rx == 0 || x.length != 3 || x.speedHz != 0 || x.bitsPerWord != 0 || x.csChange != 0 {
+		t.Fatalf("%#v", x)
+	}
+	invalid := []spi.Packet{
+		{W: w, Hz: 1 << 32},
+		{W: w, BitsPerWord: 256},
+		{W: w, Delay: 66 * time.Millisecond},
+	}
+	for i, p := range invalid {
+		if err := x.init(&p); err == nil {
+			t.Fatalf("#%d: expected error", i)
+		}
+	}
+}
+
+func TestToSpidevMode(t *testing.T) {
+	data := []struct {
+		in       spi.Mode
+		expected spi.Mode
+	}{
+		{spi.Mode0, 0},
+		{spi.Mode3, 3},
+		{spi.Mode1 | spi.HalfDuplex, 1 | threeWire},
+		{spi.Mode2 | spi.LSBFirst, 2 | lSBFirst},
+		{spi.Mode3 | spi.HalfDuplex | spi.LSBFirst, 3 | threeWire | lSBFirst},
+		{spi.Mode0 | spi.NoCS, noCS},
+	}
+	for i, line := range data {
+		if m, err := toSpidevMode(line.in); err != nil || m != line.expected {
+			t.Fatalf("#%d: 0x%x, %v", i, int(m), err)
+		}
+	}
+	if _, err := toSpidevMode(0x100); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestSPIDevParams_invalid(t *testing.T) {
+	s := SPI{}
+	if s.DevParams(0, spi.Mode0, 0) == nil {
+		t.Fatal("invalid bits")
+	}
+	if s.DevParams(0, spi.Mode0, 33) == nil {
+		t.Fatal("invalid bits")
+	}
+	if s.DevParams(-1, spi.Mode0, 8) == nil {
+		t.Fatal("invalid speed")
+	}
+	if s.DevParams(0, 0x100, 8) == nil {
+		t.Fatal("invalid mode")
+	}
+	if s.Duplex() != conn.Full {
+		t.Fatal("expected full duplex")
+	}
+}
+
+func TestSPIDuplex(t *testing.T) {
+	s := SPI{mode: spi.Mode3 | spi.HalfDuplex}
+	if s.Duplex() != conn.Half {
+		t.Fatal("expected half duplex")
+	}
+	if err := s.TxPackets([]spi.Packet{{W: []byte{1}, R: []byte{0}}}); err == nil {
+		t.Fatal("can't both write and read in half duplex")
+	}
+}
+
+func TestChunkPackets(t *testing.T) {
+	w := make([]byte, 10)
+	r := make([]byte, 10)
+	x, err := chunkPackets([]spi.Packet{{W: w, R: r}}, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Chunks are a multiple of 4 bytes.
+	if len(x) != 3 || x[0].length != 4 || x[1].length != 4 || x[2].length != 2 {
+		t.Fatalf("%#v", x)
+	}
+	if x[0].tx == 0 || x[0].rx == 0 || x[1].tx != x[0].tx+4 || x[2].rx != x[0].rx+8 {
+		t.Fatalf("%#v", x)
+	}
+	// Half-duplex write then read.
+	x, err = chunkPackets([]spi.Packet{{W: w}, {R: r[:3]}}, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x) != 2 || x[0].length != 10 || x[0].rx != 0 || x[1].length != 3 || x[1].tx != 0 {
+		t.Fatalf("%#v", x)
+	}
+	if _, err := chunkPackets([]spi.Packet{{W: w}}, 3); err == nil {
+		t.Fatal("buffer too small")
+	}
+}
+
+func TestMsgLen(t *testing.T) {
+	x := []spiIOCTransfer{{length: 4}, {length: 4}, {length: 2}}
+	if n := msgLen(x, 8); n != 2 {
+		t.Fatal(n)
+	}
+	if n := msgLen(x, 10); n != 3 {
+		t.Fatal(n)
+	}
+	if n := msgLen(x, 2); n != 1 {
+		t.Fatal(n)
+	}
+}
+
+func TestSPIMaxTxSize(t *testing.T) {
+	if n := spidevBufSize(); n < 4 {
+		t.Fatal(n)
+	}
+	s := SPI{maxTxSize: 4}
+	if n := s.MaxTxSize(); n != 4 {
+		t.Fatal(n)
+	}
+	p := []spi.Packet{{W: []byte{1, 2, 3}}, {W: []byte{1, 2}}}
+	if err := s.TxPackets(p); err == nil {
+		t.Fatal("packets are too large")
+	}
+	if _, err := s.Write(make([]byte, 5)); err == nil {
+		t.Fatal("DevParams wasn't called")
+	}
+}
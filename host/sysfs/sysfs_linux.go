@@ -8,6 +8,14 @@ import "syscall"
 
 const isLinux = true
 
+// Fault codes returned by the I²C adapter drivers.
+const (
+	errnoAddressNACK     = syscall.ENXIO
+	errnoDataNACK        = syscall.EREMOTEIO
+	errnoArbitrationLost = syscall.EAGAIN
+	errnoTimeout         = syscall.ETIMEDOUT
+)
+
 func ioctl(f uintptr, op uint, arg uintptr) error {
 	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f, uintptr(op), arg); errno != 0 {
 		return syscall.Errno(errno)
@@ -6,10 +6,22 @@
 
 package sysfs
 
-import "errors"
+import (
+	"errors"
+	"syscall"
+)
 
 const isLinux = false
 
+// Fault codes returned by the I²C adapter drivers; ioctl is never called on
+// this platform.
+const (
+	errnoAddressNACK     syscall.Errno = 0
+	errnoDataNACK        syscall.Errno = 0
+	errnoArbitrationLost syscall.Errno = 0
+	errnoTimeout         syscall.Errno = 0
+)
+
 func ioctl(f uintptr, op uint, arg uintptr) error {
 	return errors.New("sysfs: ioctl not supported on non-linux")
 }
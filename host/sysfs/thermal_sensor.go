@@ -152,10 +152,29 @@ func (d *driverThermalSensor) Init() (bool, error) {
 	return true, nil
 }
 
+// Close closes the file handles of all the sensors.
+func (d *driverThermalSensor) Close() error {
+	var err error
+	for _, t := range ThermalSensors {
+		t.mu.Lock()
+		if t.fTemp != nil {
+			if err2 := t.fTemp.Close(); err == nil {
+				err = err2
+			}
+			t.fTemp = nil
+		}
+		t.mu.Unlock()
+	}
+	ThermalSensors = nil
+	return err
+}
+
 func init() {
 	if isLinux {
 		periph.MustRegister(&driverThermalSensor{})
 	}
 }
 
+var _ periph.DriverCloser = &driverThermalSensor{}
+
 var _ devices.Environmental = &ThermalSensor{}
@@ -43,8 +43,17 @@ package periph // import "periph.io/x/periph"
 import (
 	"errors"
 	"fmt"
+	"os"
 	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/onewire/onewirereg"
+	"periph.io/x/periph/conn/pin/pinreg"
+	"periph.io/x/periph/conn/spi/spireg"
 )
 
 // Driver is an implementation for a protocol.
@@ -74,6 +83,30 @@ type Driver interface {
 	Init() (bool, error)
 }
 
+// DriverCloser is a Driver that can release the resources it acquired in
+// Init().
+//
+// Implementing it is optional.
+type DriverCloser interface {
+	Driver
+	// Close releases the resources acquired by Init(), e.g. memory mapped
+	// registers and file handles.
+	//
+	// Init() may be called again after Close() returned.
+	Close() error
+}
+
+// DriverOptIn is a Driver that is only loaded when explicitly selected by name,
+// via Opts.Only or EnvDrivers.
+//
+// This is useful for drivers that would otherwise take over the host, like a
+// simulated host.
+type DriverOptIn interface {
+	Driver
+	// OptIn is a marker method. It is never called.
+	OptIn()
+}
+
 // DriverFailure is a driver that wasn't loaded, either because it was skipped
 // or because it failed to load.
 type DriverFailure struct {
@@ -92,18 +125,74 @@ type State struct {
 	Loaded  []Driver
 	Skipped []DriverFailure
 	Failed  []DriverFailure
+	// Info contains the initialization details of every driver registered.
+	Info []DriverInfo
+}
+
+// DriverInfo describes how a driver was initialized.
+type DriverInfo struct {
+	D Driver
+	// Stage is the index of the initialization stage of the driver. The
+	// drivers in the same stage are initialized concurrently, after all the
+	// drivers in the previous stages.
+	Stage int
+	// Prerequisites are the drivers listed in D.Prerequisites().
+	Prerequisites []Driver
+	// Duration is the time spent in D.Init(). It is 0 if D.Init() wasn't
+	// called because the driver was excluded or a prerequisite wasn't loaded.
+	Duration time.Duration
+}
+
+func (d DriverInfo) String() string {
+	return fmt.Sprintf("%s: stage %d, %s", d.D, d.Stage, d.Duration)
+}
+
+// Opts are the options to select the drivers to initialize.
+//
+// Driver names are the value returned by Driver.String(). Names of drivers
+// that are not registered are ignored.
+type Opts struct {
+	// Only, when not empty, is the list of drivers to load. All the other
+	// drivers are skipped.
+	Only []string
+	// Skip is the list of drivers to not load.
+	Skip []string
 }
 
+// EnvDrivers is the environment variable to select the drivers to initialize
+// without modifying the program, for example for command line tools.
+//
+// It is a comma separated list of driver names. A name prefixed with '-' is
+// skipped, like Opts.Skip. Other names are the only ones loaded, like
+// Opts.Only. For example:
+//
+//	PERIPH_DRIVERS=-bcm283x-gpio,-lirc
+//
+// It is merged with the Opts passed to InitWithOpts(). It is also the way to
+// select a DriverOptIn, e.g. PERIPH_DRIVERS=sim to use the simulated host.
+const EnvDrivers = "PERIPH_DRIVERS"
+
 // Init initialises all the relevant drivers.
 //
 // Drivers are started concurrently.
 //
 // It is safe to call this function multiple times, the previous state is
-// returned on later calls.
+// returned on later calls until Shutdown() is called.
 //
 // Users will want to use host.Init(), which guarantees a baseline of included
 // host drivers.
 func Init() (*State, error) {
+	return InitWithOpts(nil)
+}
+
+// InitWithOpts is like Init but only initializes the drivers selected by opts
+// and by the environment variable EnvDrivers.
+//
+// The excluded drivers are listed in State.Skipped, along with the drivers
+// depending on them.
+//
+// opts is ignored if Init() or InitWithOpts() was already called.
+func InitWithOpts(opts *Opts) (*State, error) {
 	mu.Lock()
 	defer mu.Unlock()
 	if state != nil {
@@ -140,9 +229,15 @@ func Init() (*State, error) {
 	if err != nil {
 		return state, err
 	}
+	excluded := excludedDrivers(opts, os.Getenv(EnvDrivers))
 	loaded := map[string]struct{}{}
-	for _, drvs := range stages {
-		loadStage(drvs, loaded, cD, cS, cE)
+	for i, drvs := range stages {
+		state.Info = append(state.Info, loadStage(i, drvs, excluded, loaded, cD, cS, cE)...)
+		for _, d := range drvs {
+			if _, ok := loaded[d.String()]; ok {
+				initOrder = append(initOrder, d)
+			}
+		}
 	}
 	close(cD)
 	close(cS)
@@ -157,9 +252,46 @@ func Init() (*State, error) {
 	f = failures(state.Failed)
 	sort.Sort(f)
 	state.Failed = f
+	sort.Sort(infos(state.Info))
 	return state, nil
 }
 
+// Shutdown closes the loaded drivers implementing DriverCloser, in the reverse
+// order in which they were initialized, then clears the gpioreg, i2creg,
+// onewirereg, pinreg and spireg registries.
+//
+// Init() can be called again afterward. It does nothing if Init() wasn't
+// called.
+//
+// The pins and buses opened by the user are not closed; they should be closed
+// before calling Shutdown().
+func Shutdown() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if state == nil {
+		return nil
+	}
+	var errs []string
+	for i := len(initOrder) - 1; i >= 0; i-- {
+		if c, ok := initOrder[i].(DriverCloser); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, DriverFailure{c, err}.String())
+			}
+		}
+	}
+	initOrder = nil
+	state = nil
+	gpioreg.Clear()
+	i2creg.Clear()
+	onewirereg.Clear()
+	pinreg.Clear()
+	spireg.Clear()
+	if len(errs) != 0 {
+		return fmt.Errorf("periph: failed to close drivers: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
 // Register registers a driver to be initialized automatically on Init().
 //
 // The d.String() value must be unique across all registered drivers.
@@ -197,6 +329,7 @@ var (
 	allDrivers []Driver
 	byName     = map[string]Driver{}
 	state      *State
+	initOrder  []Driver // loaded drivers, in initialization order
 )
 
 // explodeStages creates multiple stages if needed.
@@ -249,12 +382,65 @@ func explodeStages(drvs []Driver) ([][]Driver, error) {
 	return stages, nil
 }
 
+// excludedDrivers returns the drivers excluded by opts and env, along with
+// the reason.
+func excludedDrivers(opts *Opts, env string) map[string]error {
+	var only, skip []string
+	if opts != nil {
+		only = opts.Only
+		skip = opts.Skip
+	}
+	var envOnly []string
+	for _, n := range strings.Split(env, ",") {
+		if n = strings.TrimSpace(n); n == "" {
+			continue
+		}
+		if n[0] == '-' {
+			skip = append(skip, n[1:])
+		} else {
+			envOnly = append(envOnly, n)
+		}
+	}
+	excluded := map[string]error{}
+	allowed := map[string]struct{}{}
+	for _, n := range append(only, envOnly...) {
+		allowed[n] = struct{}{}
+	}
+	for n, d := range byName {
+		if _, ok := allowed[n]; ok {
+			continue
+		}
+		if len(allowed) != 0 {
+			excluded[n] = errors.New("excluded: not in the list of drivers to load")
+		} else if _, ok := d.(DriverOptIn); ok {
+			excluded[n] = errors.New("excluded: opt-in driver not selected")
+		}
+	}
+	for _, n := range skip {
+		if _, ok := byName[n]; ok {
+			excluded[n] = errors.New("excluded: in the list of drivers to skip")
+		}
+	}
+	return excluded
+}
+
 // loadStage loads all the drivers in this stage concurrently.
-func loadStage(drvs []Driver, loaded map[string]struct{}, cD chan<- Driver, cS chan<- DriverFailure, cE chan<- DriverFailure) {
+//
+// It returns the initialization details of each driver.
+func loadStage(stage int, drvs []Driver, excluded map[string]error, loaded map[string]struct{}, cD chan<- Driver, cS chan<- DriverFailure, cE chan<- DriverFailure) []DriverInfo {
 	var wg sync.WaitGroup
 	// Use int for concurrent access.
 	skip := make([]error, len(drvs))
+	info := make([]DriverInfo, len(drvs))
 	for i, d := range drvs {
+		info[i] = DriverInfo{D: d, Stage: stage}
+		for _, dep := range d.Prerequisites() {
+			info[i].Prerequisites = append(info[i].Prerequisites, byName[dep])
+		}
+		if err, ok := excluded[d.String()]; ok {
+			skip[i] = err
+			continue
+		}
 		// Load only the driver if prerequisites were loaded. They are
 		// guaranteed to be in a previous stage by explodeStages().
 		for _, dep := range d.Prerequisites() {
@@ -273,7 +459,10 @@ func loadStage(drvs []Driver, loaded map[string]struct{}, cD chan<- Driver, cS c
 		wg.Add(1)
 		go func(d Driver, j int) {
 			defer wg.Done()
-			if ok, err := d.Init(); ok {
+			start := time.Now()
+			ok, err := d.Init()
+			info[j].Duration = time.Since(start)
+			if ok {
 				if err == nil {
 					cD <- d
 					return
@@ -282,7 +471,9 @@ func loadStage(drvs []Driver, loaded map[string]struct{}, cD chan<- Driver, cS c
 			} else {
 				// Do not assert that err != nil, as this is hard to test thoroughly.
 				cS <- DriverFailure{d, err}
-				if err != nil {
+				// Always record a non-nil error, otherwise the driver would be
+				// considered loaded and its dependents would be initialized.
+				if err == nil {
 					err = errors.New("no reason was given")
 				}
 				skip[j] = err
@@ -297,6 +488,7 @@ func loadStage(drvs []Driver, loaded map[string]struct{}, cD chan<- Driver, cS c
 		}
 		loaded[d.String()] = struct{}{}
 	}
+	return info
 }
 
 type drivers []Driver
@@ -305,6 +497,12 @@ func (d drivers) Len() int           { return len(d) }
 func (d drivers) Less(i, j int) bool { return d[i].String() < d[j].String() }
 func (d drivers) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
 
+type infos []DriverInfo
+
+func (i infos) Len() int           { return len(i) }
+func (i infos) Less(j, k int) bool { return i[j].D.String() < i[k].D.String() }
+func (i infos) Swap(j, k int)      { i[j], i[k] = i[k], i[j] }
+
 type failures []DriverFailure
 
 func (f failures) Len() int           { return len(f) }
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
 	"sort"
 	"testing"
 )
@@ -163,6 +164,184 @@ func TestDependencySkipped(t *testing.T) {
 	}
 }
 
+func TestDependencySkippedNoReason(t *testing.T) {
+	defer reset()
+	registerDrivers([]Driver{
+		&driver{
+			name:    "CPU",
+			prereqs: nil,
+			ok:      false,
+			err:     nil,
+		},
+		&driver{
+			name:    "Board",
+			prereqs: []string{"CPU"},
+			ok:      true,
+			err:     nil,
+		},
+	})
+	state, err := Init()
+	if err != nil || len(state.Loaded) != 0 || len(state.Skipped) != 2 {
+		t.Fatal(state, err)
+	}
+	if s := state.Skipped[0].String(); s != "Board: dependency not loaded: \"CPU\"" {
+		t.Fatal(s)
+	}
+}
+
+func TestInitWithOptsSkip(t *testing.T) {
+	defer reset()
+	registerDrivers([]Driver{
+		&driver{name: "CPU", ok: true},
+		&driver{name: "Board", prereqs: []string{"CPU"}, ok: true},
+		&driver{name: "Other", ok: true},
+	})
+	state, err := InitWithOpts(&Opts{Skip: []string{"CPU", "Unknown"}})
+	if err != nil || len(state.Loaded) != 1 || len(state.Skipped) != 2 {
+		t.Fatal(state, err)
+	}
+	if s := state.Loaded[0].String(); s != "Other" {
+		t.Fatal(s)
+	}
+	if s := state.Skipped[0].String(); s != "Board: dependency not loaded: \"CPU\"" {
+		t.Fatal(s)
+	}
+	if s := state.Skipped[1].String(); s != "CPU: excluded: in the list of drivers to skip" {
+		t.Fatal(s)
+	}
+}
+
+func TestInitWithOptsOnly(t *testing.T) {
+	defer reset()
+	registerDrivers([]Driver{
+		&driver{name: "CPU", ok: true},
+		&driver{name: "Board", prereqs: []string{"CPU"}, ok: true},
+		&driver{name: "Other", ok: true},
+	})
+	state, err := InitWithOpts(&Opts{Only: []string{"Board"}})
+	if err != nil || len(state.Loaded) != 0 || len(state.Skipped) != 3 {
+		t.Fatal(state, err)
+	}
+	if s := state.Skipped[1].String(); s != "CPU: excluded: not in the list of drivers to load" {
+		t.Fatal(s)
+	}
+}
+
+func TestInitWithOptsEnv(t *testing.T) {
+	defer reset()
+	defer os.Unsetenv(EnvDrivers)
+	if err := os.Setenv(EnvDrivers, "CPU, Board,-Board"); err != nil {
+		t.Fatal(err)
+	}
+	registerDrivers([]Driver{
+		&driver{name: "CPU", ok: true},
+		&driver{name: "Board", ok: true},
+		&driver{name: "Other", ok: true},
+	})
+	state, err := Init()
+	if err != nil || len(state.Loaded) != 1 || len(state.Skipped) != 2 {
+		t.Fatal(state, err)
+	}
+	if s := state.Loaded[0].String(); s != "CPU" {
+		t.Fatal(s)
+	}
+	if s := state.Skipped[0].String(); s != "Board: excluded: in the list of drivers to skip" {
+		t.Fatal(s)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	defer reset()
+	var closed []string
+	registerDrivers([]Driver{
+		&driverCloser{driver{name: "CPU", ok: true}, &closed, nil},
+		&driverCloser{driver{name: "Board", prereqs: []string{"CPU"}, ok: true}, &closed, nil},
+		&driverCloser{driver{name: "Skipped", ok: false, err: errors.New("skipped")}, &closed, nil},
+		&driver{name: "Other", ok: true},
+	})
+	if err := Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if len(closed) != 2 || closed[0] != "Board" || closed[1] != "CPU" {
+		t.Fatal(closed)
+	}
+	// Init can be called again.
+	state, err := Init()
+	if err != nil || len(state.Loaded) != 3 {
+		t.Fatal(state, err)
+	}
+}
+
+func TestShutdownErr(t *testing.T) {
+	defer reset()
+	var closed []string
+	registerDrivers([]Driver{
+		&driverCloser{driver{name: "CPU", ok: true}, &closed, errors.New("oops")},
+	})
+	if _, err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Shutdown(); err == nil || err.Error() != "periph: failed to close drivers: CPU: oops" {
+		t.Fatal(err)
+	}
+	if state != nil {
+		t.Fatal("expected state to be reset")
+	}
+}
+
+func TestInitWithOptsOptIn(t *testing.T) {
+	defer reset()
+	registerDrivers([]Driver{
+		&driver{name: "CPU", ok: true},
+		&driverOptIn{driver{name: "Sim", ok: true}},
+	})
+	state, err := Init()
+	if err != nil || len(state.Loaded) != 1 || len(state.Skipped) != 1 {
+		t.Fatal(state, err)
+	}
+	if s := state.Skipped[0].String(); s != "Sim: excluded: opt-in driver not selected" {
+		t.Fatal(s)
+	}
+	reset()
+	registerDrivers([]Driver{
+		&driver{name: "CPU", ok: true},
+		&driverOptIn{driver{name: "Sim", ok: true}},
+	})
+	state, err = InitWithOpts(&Opts{Only: []string{"Sim"}})
+	if err != nil || len(state.Loaded) != 1 || state.Loaded[0].String() != "Sim" {
+		t.Fatal(state, err)
+	}
+}
+
+func TestInitInfo(t *testing.T) {
+	defer reset()
+	cpu := &driver{name: "CPU", ok: true}
+	registerDrivers([]Driver{
+		cpu,
+		&driver{name: "Board", prereqs: []string{"CPU"}, ok: true},
+		&driver{name: "Other", ok: true},
+	})
+	state, err := InitWithOpts(&Opts{Skip: []string{"Other"}})
+	if err != nil || len(state.Info) != 3 {
+		t.Fatal(state, err)
+	}
+	if i := state.Info[0]; i.D.String() != "Board" || i.Stage != 1 || len(i.Prerequisites) != 1 || i.Prerequisites[0] != cpu {
+		t.Fatal(i)
+	}
+	if i := state.Info[1]; i.D != cpu || i.Stage != 0 || len(i.Prerequisites) != 0 {
+		t.Fatal(i)
+	}
+	if s := state.Info[2].String(); s != "Other: stage 0, 0s" {
+		t.Fatal(s)
+	}
+}
+
 func TestRegisterLate(t *testing.T) {
 	defer reset()
 	if _, err := Init(); err != nil {
@@ -355,6 +534,7 @@ func reset() {
 	allDrivers = []Driver{}
 	byName = map[string]Driver{}
 	state = nil
+	initOrder = nil
 }
 
 func registerDrivers(drivers []Driver) {
@@ -381,3 +561,21 @@ func (d *driver) Prerequisites() []string {
 func (d *driver) Init() (bool, error) {
 	return d.ok, d.err
 }
+
+type driverCloser struct {
+	driver
+	closed *[]string
+	err    error
+}
+
+func (d *driverCloser) Close() error {
+	*d.closed = append(*d.closed, d.name)
+	return d.err
+}
+
+type driverOptIn struct {
+	driver
+}
+
+func (d *driverOptIn) OptIn() {
+}